@@ -1,31 +1,51 @@
 package main
 
 import (
+	"bytes"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
 	"os"
+	"regexp"
+	"sort"
 	"strings"
+	"text/tabwriter"
 )
 
 type ResourcesBundle struct {
 	ResourceChanges []ResourceChange `json:"resource_changes"`
+	Configuration   Configuration    `json:"configuration"`
 }
 
 type ResourceChange struct {
-	Address      string `json:"address"`
-	Type         string `json:"type"`
-	ProviderName string `json:"provider_name"`
-	Change       struct {
+	Address         string `json:"address"`
+	PreviousAddress string `json:"previous_address"`
+	Type            string `json:"type"`
+	ProviderName    string `json:"provider_name"`
+	Change          struct {
 		Actions []string    `json:"actions"`
 		After   interface{} `json:"after"`
+		// Same structure as After, with true for each value that is unknown until
+		// apply (computed by the provider), or sensitive.
+		AfterUnknown   interface{} `json:"after_unknown"`
+		AfterSensitive interface{} `json:"after_sensitive"`
 	} `json:"change"`
 }
 
-type Definitions struct {
-	Separator string   `json:"separator"`
-	Priority  int      `json:"priority"`
-	Variables []string `json:"variables"`
+// afterObject returns the "after" object of the planned change res. It returns nil
+// if the plan does not contain attribute values, as with the streaming output of
+// "terraform plan -json": in this case only the special variables can be used.
+func (res ResourceChange) afterObject() (map[string]interface{}, error) {
+	switch after := res.Change.After.(type) {
+	case map[string]interface{}:
+		return after, nil
+	case nil:
+		return nil, nil
+	default:
+		return nil, fmt.Errorf("resource %s: change.after: type is %s; want: object",
+			res.Address, typeName(after))
+	}
 }
 
 // Keep track of the asymmetry of import subcommand.
@@ -37,23 +57,153 @@ type Definitions struct {
 //
 //	terraform state rm res-address
 type ImportElement struct {
-	Addr string
-	ID   string
+	Addr     string
+	ID       string
+	Type     string
+	Priority int
+	// What computed ID: the key of the resources definition (a resource type or an
+	// address rule), or idsInventoryDefinition.
+	Definition string
+	// The aliased provider configuration of the resource, if any: NAME.ALIAS or, if
+	// declared in a module, MODULE:NAME.ALIAS (see providerAliases).
+	Provider string
+	// The dependency depth of the resource, if ordering by dependencies (see
+	// dependencyDepths).
+	Depth int
+}
+
+// The Definition of an ImportElement whose ID comes from the ID inventory.
+const idsInventoryDefinition = "ids inventory"
+
+// A resource to create that Import skipped, and why.
+type SkippedElement struct {
+	Addr   string
+	Type   string
+	Reason string
+}
+
+// Options of Import.
+type ImportOptions struct {
+	// Within the same priority, import the parents before their dependents, according
+	// to the references in the configuration of the JSON plan.
+	OrderByDeps bool
+	// Attribute values supplied by the operator, by resource address, then by field
+	// reference. They take precedence over the plan, and allow to use attributes that
+	// are unknown until apply or sensitive.
+	AttrValues map[string]map[string]string
+	// Import IDs supplied by the operator. They take precedence over the resource
+	// definitions.
+	IDs idInventory
+	// Terraform states of other roots, for the field references to their resources
+	// (see stateRefPrefix).
+	LookupStates lookupStates
+	// Import only the resources of these types (all if empty).
+	Types []string
+	// Import only the resources whose address matches one of these address globs (all
+	// if empty), then skip those whose address matches one of Exclude.
+	Include []string
+	Exclude []string
+	// Fail if a resource to import has no resources definition, instead of skipping it
+	// with a warning.
+	Strict bool
+	// Warn about duplicate import IDs, empty ID components and ID components containing
+	// the separator, instead of failing (see checkImportID and duplicateImportIDs).
+	AllowDuplicateIDs bool
+	// Where to emit warnings and the summary. If nil, as text to stderr.
+	Diagnostics *diagnostics
+}
+
+// Options of the import scripts.
+type ScriptOptions struct {
+	// Terraform options to add to each command (see splitTerraformArgs).
+	TFArgs []string
+	// If greater than 0, generate an up script that imports in parallel, with up to
+	// Parallel workers, into the local state LocalState (see importParallelUpScript).
+	Parallel   int
+	LocalState string
+	// Add -provider=NAME.ALIAS to the "terraform import" of the resources of aliased
+	// providers (see providerFlag). Only Terraform before 0.13 needs it; later
+	// versions deprecated and then removed it.
+	ProviderFlag bool
 }
 
-func doImport(upPath, downPath, srcPlanPath, resourcesDefinitions string) error {
-	definitionsFile, err := os.Open(resourcesDefinitions)
+// doImport generates the up and down import scripts, according to script. If dryRun,
+// it writes instead a report to out, in dryRunFormat ("text" or "json"). The format
+// is validated even without dryRun, as the other options.
+func doImport(upPath, downPath, srcPlanPath string, resourcesDefinitions []string,
+	attrValuesPath, idsPath string, lookupStatePaths []string,
+	terraformBin string, dryRun bool, dryRunFormat string, script ScriptOptions,
+	out io.Writer, opts ImportOptions,
+) error {
+	if dryRunFormat != "text" && dryRunFormat != "json" {
+		return fmt.Errorf("dry-run format: %q; want: text or json", dryRunFormat)
+	}
+	if script.Parallel < 0 {
+		return fmt.Errorf("parallel: %d workers; want: at least 1", script.Parallel)
+	}
+	if global, _ := splitTerraformArgs(script.TFArgs); script.Parallel > 0 && global != "" {
+		// The workers copy the state relative to the current directory, while
+		// terraform would look for it relative to the -chdir directory.
+		return fmt.Errorf("parallel: -chdir is not supported; run the script from " +
+			"the root directory instead")
+	}
+
+	configs, err := loadDefinitions(resourcesDefinitions)
 	if err != nil {
-		return fmt.Errorf("opening the definitions file: %v", err)
+		return err
+	}
+
+	if attrValuesPath != "" {
+		opts.AttrValues, err = loadAttrValues(attrValuesPath)
+		if err != nil {
+			return err
+		}
+	}
+	if idsPath != "" {
+		opts.IDs, err = loadIDInventory(idsPath)
+		if err != nil {
+			return err
+		}
+	}
+	if len(lookupStatePaths) > 0 {
+		opts.LookupStates, err = loadLookupStates(lookupStatePaths)
+		if err != nil {
+			return err
+		}
 	}
-	defer definitionsFile.Close()
 
-	srcPlanFile, err := os.Open(srcPlanPath)
+	srcPlanFile, err := openPlan(srcPlanPath, terraformBin)
 	if err != nil {
 		return fmt.Errorf("opening the terraform plan file: %v", err)
 	}
 	defer srcPlanFile.Close()
 
+	imports, removals, skipped, err := Import(srcPlanFile, configs, opts)
+	if err != nil {
+		return fmt.Errorf("parse src-plan: %v", err)
+	}
+
+	if dryRun {
+		return importReport(imports, skipped, dryRunFormat, out)
+	}
+
+	if script.ProviderFlag {
+		diags := opts.Diagnostics
+		if diags == nil {
+			diags = &diagnostics{out: os.Stderr}
+		}
+		for _, elem := range imports {
+			if isModuleProvider(elem.Provider) {
+				// -provider would name the configuration of the root module with the
+				// same NAME.ALIAS, which can be a different one or not exist.
+				diags.warn("module-provider", elem.Addr, elem.Type,
+					"resource %s: provider configuration %s is declared in its module, "+
+						"which -provider cannot name: check that terraform imports it "+
+						"with the right configuration", elem.Addr, elem.Provider)
+			}
+		}
+	}
+
 	upFile, err := os.Create(upPath)
 	if err != nil {
 		return fmt.Errorf("creating the up file: %v", err)
@@ -66,141 +216,580 @@ func doImport(upPath, downPath, srcPlanPath, resourcesDefinitions string) error
 	}
 	defer downFile.Close()
 
-	imports, removals, err := Import(srcPlanFile, definitionsFile)
-	if err != nil {
-		return fmt.Errorf("parse src-plan: %v", err)
+	if script.Parallel > 0 {
+		err = importParallelUpScript(imports, script, upFile)
+	} else {
+		err = importUpScript(imports, script, upFile)
 	}
-
-	if err := importUpScript(imports, upFile); err != nil {
+	if err != nil {
 		return fmt.Errorf("writing the up script: %v", err)
 	}
-	if err := importDownScript(removals, downFile); err != nil {
+	if err := importDownScript(removals, script, downFile); err != nil {
 		return fmt.Errorf("writing the down script: %v", err)
 	}
 
 	return nil
 }
 
-func Import(rd, definitionsFile io.Reader) ([]ImportElement, []ImportElement, error) {
+// Import returns the elements to import, in import order, the elements to remove to
+// undo the import, in removal order, and the resources to create that it skipped.
+//
+// The import order is by decreasing priority of the resource definitions, then (if
+// opts.OrderByDeps) by increasing dependency depth, then by order in the plan.
+// The removal order is the reverse of the import order.
+func Import(rd io.Reader, configs map[string]Definitions, opts ImportOptions,
+) ([]ImportElement, []ImportElement, []SkippedElement, error) {
 	var imports []ImportElement
 	var removals []ImportElement
-	var configs map[string]Definitions
-	var resourcesBundle ResourcesBundle
+	var skipped []SkippedElement
 	var filteredResources []ResourceChange
 
-	plan, err := io.ReadAll(rd)
+	resourcesBundle, err := readResourcesBundle(rd)
 	if err != nil {
-		return imports, removals,
-			fmt.Errorf("reading the plan file: %s", err)
-	}
-	if err = json.Unmarshal(plan, &resourcesBundle); err != nil {
-		return imports, removals,
-			fmt.Errorf("parsing the plan: %s", err)
+		return imports, removals, skipped, err
 	}
 
-	defs, err := io.ReadAll(definitionsFile)
-	if err != nil {
-		return imports, removals,
-			fmt.Errorf("reading the definitions file: %s", err)
+	diags := opts.Diagnostics
+	if diags == nil {
+		diags = &diagnostics{out: os.Stderr}
 	}
-	if err = json.Unmarshal(defs, &configs); err != nil {
-		return imports, removals,
-			fmt.Errorf("parsing resources definitions: %s", err)
+	summaries := map[string]*typeSummary{}
+	summaryOf := func(resType string) *typeSummary {
+		if summaries[resType] == nil {
+			summaries[resType] = &typeSummary{}
+		}
+		return summaries[resType]
 	}
 
-	// Return objects in the correct order if 'priority' parameter is set in provider configuration.
-	// The remove order is reversed (LIFO logic).
-
-	// Filter all "create" resources before going further
+	// Filter all "create" resources before going further. A replaced resource
+	// already exists in the state, so it must not be imported.
+	var replaced []string
 	for _, resource := range resourcesBundle.ResourceChanges {
-		if resource.Change.Actions[0] == "create" {
+		kind, err := resource.kind()
+		if err != nil {
+			return imports, removals, skipped, err
+		}
+		switch kind {
+		case changeCreate:
 			filteredResources = append(filteredResources, resource)
+		case changeReplace:
+			replaced = append(replaced, resource.Address)
+			summaryOf(resource.Type).replaced++
+			skipped = append(skipped, SkippedElement{
+				Addr:   resource.Address,
+				Type:   resource.Type,
+				Reason: "planned for replacement",
+			})
+			diags.warn("replaced", resource.Address, resource.Type,
+				"skipping resource %s, planned for replacement "+
+					"(it already exists in the state)", resource.Address)
 		}
 	}
 
 	if len(filteredResources) == 0 {
-		return imports, removals,
+		if len(replaced) > 0 {
+			return imports, removals, skipped, fmt.Errorf("src-plan doesn't contains resources "+
+				"to create (only %d resources to replace)", len(replaced))
+		}
+		return imports, removals, skipped,
 			fmt.Errorf("src-plan doesn't contains resources to create")
 	}
 
+	// Catch typos in the addresses of the attribute values, which would otherwise be
+	// silently ignored.
+	toCreate := map[string]bool{}
 	for _, resource := range filteredResources {
-		// Proceed only if type is declared in resources definitions
-		if _, ok := configs[resource.Type]; !ok {
-			msg := fmt.Sprintf("Warning: resource %s is not defined. Check %s documentation\n",
-				resource.Type, resource.ProviderName)
-			fmt.Printf("\033[1;33m%s\033[0m", msg)
-			continue
+		toCreate[resource.Address] = true
+	}
+	addrs := make([]string, 0, len(opts.AttrValues))
+	for addr := range opts.AttrValues {
+		addrs = append(addrs, addr)
+	}
+	for _, addr := range sorted(addrs) {
+		if !toCreate[addr] {
+			return imports, removals, skipped, fmt.Errorf("attribute values: resource %s "+
+				"is not a resource to create in src-plan", addr)
 		}
-		resourceParams := configs[resource.Type]
-		var resID []string
-		after := resource.Change.After.(map[string]interface{})
-		for _, field := range resourceParams.Variables {
-			if _, ok := after[field]; !ok {
-				return imports, removals,
-					fmt.Errorf(
-						"error in resources definition %s: field '%s' doesn't exist in plan",
-						resource.Type, field)
+	}
+	addrs = addrs[:0]
+	for _, resource := range filteredResources {
+		addrs = append(addrs, resource.Address)
+	}
+	if err := opts.IDs.check(addrs); err != nil {
+		return imports, removals, skipped, err
+	}
+
+	// The attribute values and the ID inventory are checked above against all the
+	// resources to create, so that the same files can be used with different filters.
+	total := len(filteredResources)
+	kept, results, err := filterResources(filteredResources, opts)
+	if err != nil {
+		return imports, removals, skipped, err
+	}
+	if len(results) > 0 {
+		removedBy := map[string]string{}
+		removed := map[string]int{}
+		var details []string
+		for _, result := range results {
+			for _, addr := range result.removed {
+				removedBy[addr] = result.option
 			}
-			subID, ok := after[field].(string)
-			if !ok {
-				return imports, removals,
-					fmt.Errorf("resource_changes: after: %s: type is %T; want: string",
-						field, after[field])
+			removed[result.option] = len(result.removed)
+			details = append(details, fmt.Sprintf("%s: %d", result.option,
+				len(result.removed)))
+		}
+		for _, resource := range filteredResources {
+			if option, ok := removedBy[resource.Address]; ok {
+				summaryOf(resource.Type).filtered++
+				skipped = append(skipped, SkippedElement{
+					Addr:   resource.Address,
+					Type:   resource.Type,
+					Reason: "removed by " + option,
+				})
+			}
+		}
+		diags.emit(diagnostic{
+			Severity: "info",
+			Code:     "filtered",
+			Message: fmt.Sprintf("filters removed %d of %d resources to create (%s)",
+				total-len(kept), total, strings.Join(details, ", ")),
+			Counts: removed,
+		})
+	}
+	filteredResources = kept
+	if len(filteredResources) == 0 {
+		return imports, removals, skipped, fmt.Errorf("src-plan doesn't contains resources "+
+			"to create (all %d removed by the filters)", total)
+	}
+
+	var undefined []ResourceChange
+	var idProblems []idProblem
+	for _, resource := range filteredResources {
+		resourceParams, defKey, defined, err := definitionFor(configs, resource.Address,
+			resource.Type)
+		if err != nil {
+			return imports, removals, skipped, err
+		}
+
+		// The ID inventory takes precedence over the resources definitions.
+		resID, found, err := opts.IDs.lookup(resource.Address)
+		if err != nil {
+			return imports, removals, skipped, err
+		}
+		if found {
+			idProblems = append(idProblems, checkImportID(resource.Address, resource.Type,
+				resID, Definitions{}, nil)...)
+			summaryOf(resource.Type).toImport++
+			imports = append(imports, ImportElement{
+				Addr:       resource.Address,
+				ID:         resID,
+				Type:       resource.Type,
+				Priority:   resourceParams.Priority,
+				Definition: idsInventoryDefinition,
+			})
+			continue
+		}
+
+		// Proceed only if a resources definition applies
+		if !defined {
+			undefined = append(undefined, resource)
+			summaryOf(resource.Type).undefined++
+			skipped = append(skipped, SkippedElement{
+				Addr:   resource.Address,
+				Type:   resource.Type,
+				Reason: "no resources definition",
+			})
+			if !opts.Strict {
+				diags.warn("undefined", resource.Address, resource.Type,
+					"resource %s is not defined. Check %s documentation",
+					resource.Type, resource.ProviderName)
 			}
-			resID = append(resID, subID)
+			continue
 		}
+		after, err := resource.afterObject()
+		if err != nil {
+			return imports, removals, skipped, err
+		}
+		resID, components, err := resourceParams.importID(resource, after,
+			opts.AttrValues[resource.Address], opts.LookupStates)
+		if err != nil {
+			return imports, removals, skipped, err
+		}
+		idProblems = append(idProblems, checkImportID(resource.Address, resource.Type,
+			resID, resourceParams, components)...)
 
-		elem := ImportElement{
-			Addr: resource.Address,
-			ID:   strings.Join(resID, resourceParams.Separator)}
+		summaryOf(resource.Type).toImport++
+		imports = append(imports, ImportElement{
+			Addr:       resource.Address,
+			ID:         resID,
+			Type:       resource.Type,
+			Priority:   resourceParams.Priority,
+			Definition: defKey,
+		})
+	}
 
-		if resourceParams.Priority == 1 {
-			// Prepend
-			imports = append([]ImportElement{elem}, imports...)
-		} else {
-			// Append
-			imports = append(imports, elem)
+	if opts.Strict && len(undefined) > 0 {
+		var errs []error
+		for _, resource := range undefined {
+			errs = append(errs, fmt.Errorf("resource %s: type %s is not defined. "+
+				"Check %s documentation", resource.Address, resource.Type,
+				resource.ProviderName))
 		}
+		return imports, removals, skipped, fmt.Errorf("strict mode: %d resources without a "+
+			"resources definition:\n%w", len(undefined), errors.Join(errs...))
 	}
 
 	if len(imports) == 0 {
-		return imports, removals,
+		return imports, removals, skipped,
 			fmt.Errorf("src-plan contains only undefined resources")
 	}
 
+	// Two imports of the same object, or an ID that the provider would split
+	// differently, would fail or, worse, import the wrong object.
+	idProblems = append(idProblems, duplicateImportIDs(imports)...)
+	if opts.AllowDuplicateIDs {
+		for _, p := range idProblems {
+			diags.warn(p.code, p.addr, p.resType, "%s", p.msg)
+		}
+	} else if len(idProblems) > 0 {
+		var errs []error
+		for _, p := range idProblems {
+			errs = append(errs, errors.New(p.msg))
+		}
+		return imports, removals, skipped, fmt.Errorf("%d problems with the import IDs "+
+			"(to ignore them, use --allow-duplicate-ids):\n%w", len(idProblems),
+			errors.Join(errs...))
+	}
+
+	depths := map[string]int{}
+	if opts.OrderByDeps {
+		if !resourcesBundle.Configuration.hasConfiguration() {
+			return imports, removals, skipped,
+				fmt.Errorf("ordering by dependencies requires the configuration " +
+					"in the plan (use the output of 'terraform show -json')")
+		}
+		depths, err = dependencyDepths(resourcesBundle.Configuration.dependencies())
+		if err != nil {
+			return imports, removals, skipped, fmt.Errorf("ordering by dependencies: %s", err)
+		}
+	}
+	// Addresses are well-formed, since they have been generated by Terraform.
+	depth := func(elem ImportElement) int {
+		cfgAddr, _ := configAddress(elem.Addr)
+		return depths[cfgAddr]
+	}
+	sort.SliceStable(imports, func(i, j int) bool {
+		if imports[i].Priority != imports[j].Priority {
+			return imports[i].Priority > imports[j].Priority
+		}
+		return depth(imports[i]) < depth(imports[j])
+	})
+
+	for i := range imports {
+		imports[i].Depth = depth(imports[i])
+	}
+
+	aliases := resourcesBundle.Configuration.providerAliases()
+	for i := range imports {
+		cfgAddr, _ := configAddress(imports[i].Addr)
+		imports[i].Provider = aliases[cfgAddr]
+	}
+
 	// The removals are the reverse of the imports.
 	removals = make([]ImportElement, 0, len(imports))
 	for i := len(imports) - 1; i >= 0; i-- {
 		removals = append(removals, imports[i])
 	}
 
-	return imports, removals, nil
+	diags.summary(summaries)
+	return imports, removals, skipped, nil
+}
+
+// loadAttrValues loads the attribute values supplied by the operator (see
+// ImportOptions) from the JSON file at path, for example:
+//
+//	{
+//	  "aws_iam_role.deployer": {"account_id": "123456789012"}
+//	}
+func loadAttrValues(path string) (map[string]map[string]string, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("opening the attribute values file: %v", err)
+	}
+	var values map[string]map[string]string
+	if err := json.Unmarshal(data, &values); err != nil {
+		return nil, fmt.Errorf("parsing attribute values %s: %s", path, err)
+	}
+	return values, nil
+}
+
+// readResourcesBundle reads a plan: the output of "terraform show -json", the
+// streaming output of "terraform plan -json" or the output of "terraform plan
+// -no-color" (see readTextPlan). Only the first has the configuration. An empty
+// plan is reported as invalid JSON.
+func readResourcesBundle(rd io.Reader) (ResourcesBundle, error) {
+	var resourcesBundle ResourcesBundle
+
+	plan, err := io.ReadAll(rd)
+	if err != nil {
+		return resourcesBundle, fmt.Errorf("reading the plan file: %s", err)
+	}
+	switch {
+	case isStream(plan):
+		resourcesBundle.ResourceChanges, err = readStream(bytes.NewReader(plan))
+		if err != nil {
+			return resourcesBundle, err
+		}
+	case len(bytes.TrimSpace(plan)) > 0 && !isJSONPlan(plan):
+		resourcesBundle.ResourceChanges, err = readTextPlan(bytes.NewReader(plan))
+		if err != nil {
+			return resourcesBundle, err
+		}
+	default:
+		if err = unmarshalUseNumber(plan, &resourcesBundle); err != nil {
+			return resourcesBundle, fmt.Errorf("parsing the plan: %s", err)
+		}
+	}
+	return resourcesBundle, nil
+}
+
+// unmarshalUseNumber is like json.Unmarshal, but decodes numbers as json.Number
+// instead of float64, so that they keep their textual representation (an ID like
+// 2817139 would otherwise become 2.817139e+06).
+func unmarshalUseNumber(data []byte, v interface{}) error {
+	if !json.Valid(data) {
+		// Get the same syntax errors as json.Unmarshal.
+		return json.Unmarshal(data, v)
+	}
+	dec := json.NewDecoder(bytes.NewReader(data))
+	dec.UseNumber()
+	return dec.Decode(v)
+}
+
+// importReport writes to out what Import would do, in format "text" or "json": the
+// elements to import, in import order, and the skipped resources.
+func importReport(imports []ImportElement, skipped []SkippedElement, format string,
+	out io.Writer,
+) error {
+	if format == "json" {
+		type jsonImport struct {
+			Address    string `json:"address"`
+			Type       string `json:"type"`
+			ID         string `json:"id"`
+			Definition string `json:"definition"`
+			Priority   int    `json:"priority"`
+			Provider   string `json:"provider,omitempty"`
+		}
+		type jsonSkipped struct {
+			Address string `json:"address"`
+			Type    string `json:"type"`
+			Reason  string `json:"reason"`
+		}
+		report := struct {
+			Imports []jsonImport  `json:"imports"`
+			Skipped []jsonSkipped `json:"skipped"`
+		}{
+			Imports: make([]jsonImport, 0, len(imports)),
+			Skipped: make([]jsonSkipped, 0, len(skipped)),
+		}
+		for _, elem := range imports {
+			report.Imports = append(report.Imports, jsonImport{
+				elem.Addr, elem.Type, elem.ID, elem.Definition, elem.Priority, elem.Provider})
+		}
+		for _, elem := range skipped {
+			report.Skipped = append(report.Skipped, jsonSkipped{
+				elem.Addr, elem.Type, elem.Reason})
+		}
+		enc := json.NewEncoder(out)
+		enc.SetIndent("", "  ")
+		return enc.Encode(report)
+	}
+
+	tw := tabwriter.NewWriter(out, 0, 0, 2, ' ', 0)
+	fmt.Fprintf(tw, "Resources to import, in import order:\n")
+	fmt.Fprintf(tw, "  ADDRESS\tTYPE\tID\tDEFINITION\tPRIORITY\n")
+	for _, elem := range imports {
+		fmt.Fprintf(tw, "  %s\t%s\t%s\t%s\t%d\n", elem.Addr, elem.Type, elem.ID,
+			elem.Definition, elem.Priority)
+	}
+	if len(skipped) > 0 {
+		fmt.Fprintf(tw, "\nSkipped resources:\n")
+		fmt.Fprintf(tw, "  ADDRESS\tTYPE\tREASON\n")
+		for _, elem := range skipped {
+			fmt.Fprintf(tw, "  %s\t%s\t%s\n", elem.Addr, elem.Type, elem.Reason)
+		}
+	}
+	return tw.Flush()
 }
 
-func importUpScript(elements []ImportElement, out io.Writer) error {
-	cmd := "terraform import"
-	fmt.Fprintf(out, importScriptHeader, cmd, len(elements))
+// importUpScript writes the import script. The Terraform options script.TFArgs are
+// added to each "terraform import" command (see splitTerraformArgs).
+func importUpScript(elements []ImportElement, script ScriptOptions, out io.Writer) error {
+	global, sub := splitTerraformArgs(script.TFArgs)
+	cmd := "terraform" + global + " import"
+	fmt.Fprintf(out, importScriptHeader, "terraform import", len(elements),
+		terraformArgsComment(script.TFArgs))
 	for _, elem := range elements {
-		fmt.Fprintf(out, "%s \\\n    %q %q\n\n", cmd, elem.Addr, elem.ID)
+		fmt.Fprintf(out, "%s%s%s \\\n    %q %q\n\n", cmd, providerFlag(elem, script), sub,
+			elem.Addr, elem.ID)
 	}
 	return nil
 }
 
-func importDownScript(elements []ImportElement, out io.Writer) error {
-	cmd := "terraform state rm"
-	fmt.Fprintf(out, importScriptHeader, cmd, len(elements))
+// providerFlag returns the -provider option of the "terraform import" of elem, with a
+// leading space, or the empty string if not script.ProviderFlag. A configuration
+// declared in a module cannot be named by -provider.
+func providerFlag(elem ImportElement, script ScriptOptions) string {
+	if !script.ProviderFlag || elem.Provider == "" || isModuleProvider(elem.Provider) {
+		return ""
+	}
+	return " -provider=" + elem.Provider
+}
+
+// importDownScript writes the script to undo the import. Of the Terraform options,
+// only the global ones apply to "terraform state rm". If the up script is parallel,
+// the resources are removed from its local state, and the addresses are quoted with
+// shellQuote as in importParallelUpScript.
+func importDownScript(elements []ImportElement, script ScriptOptions, out io.Writer,
+) error {
+	global, _ := splitTerraformArgs(script.TFArgs)
+	cmd := "terraform" + global + " state rm"
+	if script.Parallel > 0 {
+		cmd += " -state=" + shellQuote(script.LocalState)
+	}
+	fmt.Fprintf(out, importScriptHeader, "terraform state rm", len(elements),
+		terraformArgsComment(script.TFArgs))
+	if script.Parallel > 0 {
+		fmt.Fprint(out, localBackendCheck)
+	}
 	for _, elem := range elements {
-		fmt.Fprintf(out, "%s \\\n    %q\n\n", cmd, elem.Addr)
+		addr := fmt.Sprintf("%q", elem.Addr)
+		if script.Parallel > 0 {
+			addr = shellQuote(elem.Addr)
+		}
+		fmt.Fprintf(out, "%s \\\n    %s\n\n", cmd, addr)
+	}
+	return nil
+}
+
+// importParallelUpScript writes an import script that imports the elements level by
+// level, where a level is a run of elements with the same priority and dependency
+// depth. The elements of a level are imported with up to script.Parallel workers
+// ("xargs -P"), each importing one element into its own copy of the local state
+// script.LocalState. After each level, the copies are merged back into the local
+// state with "terravalet merge-states", so that the next level starts from there.
+//
+// To keep all the commands in a single script, the workers are the script itself,
+// invoked as "sh SCRIPT worker N" to import element N.
+func importParallelUpScript(elements []ImportElement, script ScriptOptions,
+	out io.Writer,
+) error {
+	_, sub := splitTerraformArgs(script.TFArgs)
+	state := shellQuote(script.LocalState)
+	workDir := script.LocalState + ".import.d"
+	copyPath := func(n int) string {
+		return shellQuote(fmt.Sprintf("%s/%d.tfstate", workDir, n))
+	}
+
+	fmt.Fprintf(out, importScriptHeader, "terraform import", len(elements),
+		terraformArgsComment(script.TFArgs))
+	fmt.Fprintf(out, "# Worker: import item N into its own copy of the state.\n")
+	fmt.Fprintf(out, "if [ \"$1\" = worker ]; then\n")
+	fmt.Fprintf(out, "    cp %s %s/\"$2\".tfstate\n", state, shellQuote(workDir))
+	fmt.Fprintf(out, "    case $2 in\n")
+	for i, elem := range elements {
+		fmt.Fprintf(out, "    %d) terraform import -state=%s%s%s \\\n"+
+			"        %s %s ;;\n", i+1, copyPath(i+1), providerFlag(elem, script), sub,
+			shellQuote(elem.Addr), shellQuote(elem.ID))
+	}
+	fmt.Fprintf(out, "    esac\n    exit\nfi\n\n")
+	fmt.Fprint(out, localBackendCheck)
+	fmt.Fprintf(out, "rm -rf %s\nmkdir %s\n\n", shellQuote(workDir), shellQuote(workDir))
+
+	for start := 0; start < len(elements); {
+		end := start + 1
+		for end < len(elements) && elements[end].Priority == elements[start].Priority &&
+			elements[end].Depth == elements[start].Depth {
+			end++
+		}
+		var items, copies []string
+		for n := start + 1; n <= end; n++ {
+			items = append(items, fmt.Sprint(n))
+			copies = append(copies, copyPath(n))
+		}
+		fmt.Fprintf(out, "# Priority %d, dependency depth %d: %d items.\n",
+			elements[start].Priority, elements[start].Depth, end-start)
+		// A failed import must stop the script, before its level is merged and its
+		// dependents are imported.
+		fmt.Fprintf(out, "printf '%%s\\n' %s | xargs -P %d -n 1 sh \"$0\" worker ||\n"+
+			"    { echo %s >&2; exit 1; }\n", strings.Join(items, " "), script.Parallel,
+			shellQuote("import failed; the copies of the state are in "+workDir))
+		fmt.Fprintf(out, "terravalet merge-states --state %s \\\n    %s\n\n", state,
+			strings.Join(copies, " \\\n    "))
+		start = end
 	}
+
+	fmt.Fprintf(out, "rm -rf %s\n", shellQuote(workDir))
 	return nil
 }
 
+// splitTerraformArgs splits the Terraform options args in the global options, that
+// must come before the subcommand (-chdir), and the options of the subcommand (like
+// -var-file). It returns each group shell-quoted, with a leading space if not empty.
+func splitTerraformArgs(args []string) (string, string) {
+	var global, sub string
+	for _, arg := range args {
+		if strings.HasPrefix(arg, "-chdir=") {
+			global += " " + shellQuote(arg)
+		} else {
+			sub += " " + shellQuote(arg)
+		}
+	}
+	return global, sub
+}
+
+// terraformArgsComment returns the line of the script header listing the Terraform
+// options args, or the empty string if there are none.
+func terraformArgsComment(args []string) string {
+	if len(args) == 0 {
+		return ""
+	}
+	quoted := make([]string, 0, len(args))
+	for _, arg := range args {
+		quoted = append(quoted, shellQuote(arg))
+	}
+	return "# Terraform options: " + strings.Join(quoted, " ") + "\n"
+}
+
+// shellQuote quotes s for the POSIX shell, if needed.
+func shellQuote(s string) string {
+	if s != "" && reShellSafe.MatchString(s) {
+		return s
+	}
+	return "'" + strings.ReplaceAll(s, "'", `'\''`) + "'"
+}
+
+var reShellSafe = regexp.MustCompile(`^[A-Za-z0-9_@%+=:,./-]+$`)
+
+// localBackendCheck is the part of the parallel scripts that stops them unless the
+// backend of the root module is local: terraform honours -state only with the local
+// backend and, with another one, all the workers would write the remote state.
+// Without a backend block there is no backend configuration, which is the local
+// backend too.
+const localBackendCheck = `backend=${TF_DATA_DIR:-.terraform}/terraform.tfstate
+if [ -f "$backend" ] && ! grep -q '"type": *"local"' "$backend"; then
+    echo "the backend is not local: -state would be ignored (see --parallel in the README)" >&2
+    exit 1
+fi
+
+`
+
 const importScriptHeader = `#! /bin/sh
 # DO NOT EDIT. Generated by terravalet.
 # WARNING: check the order of resources before running this script.
 #
 # This script will %q %d items.
-
+%s
 # Uncomment this if you want to stop the script at first error
 # set -e
 set -x
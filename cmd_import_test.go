@@ -1,10 +1,15 @@
 package main
 
-import "testing"
+import (
+	"testing"
+
+	"github.com/go-quicktest/qt"
+)
 
 func TestRunImportSuccess(t *testing.T) {
 	testCases := []struct {
 		name         string
+		options      []string
 		resDefs      string
 		srcPlanPath  string
 		wantUpPath   string
@@ -17,6 +22,131 @@ func TestRunImportSuccess(t *testing.T) {
 			wantUpPath:   "testdata/import/08_import_up.sh",
 			wantDownPath: "testdata/import/08_import_down.sh",
 		},
+		{
+			name:         "import resources with id_template",
+			resDefs:      "testdata/import/14_import_id_template_definitions.json",
+			srcPlanPath:  "testdata/import/14_import_src-plan.json",
+			wantUpPath:   "testdata/import/14_import_up.sh",
+			wantDownPath: "testdata/import/14_import_down.sh",
+		},
+		{
+			name:         "import resources with nested, non-string and special fields",
+			resDefs:      "testdata/import/17_import_fields_definitions.json",
+			srcPlanPath:  "testdata/import/17_import_fields_src-plan.json",
+			wantUpPath:   "testdata/import/17_import_fields_up.sh",
+			wantDownPath: "testdata/import/17_import_fields_down.sh",
+		},
+		{
+			name:         "text plan",
+			resDefs:      "testdata/import/17_import_fields_definitions.json",
+			srcPlanPath:  "testdata/import/35_import_src-plan.txt",
+			wantUpPath:   "testdata/import/35_import_up.sh",
+			wantDownPath: "testdata/import/35_import_down.sh",
+		},
+		{
+			name:         "import resources ordered by priority",
+			resDefs:      "testdata/import/18_import_priority_definitions.json",
+			srcPlanPath:  "testdata/import/18_import_src-plan.json",
+			wantUpPath:   "testdata/import/18_import_up.sh",
+			wantDownPath: "testdata/import/18_import_down.sh",
+		},
+		{
+			name:         "import resources with YAML definitions",
+			resDefs:      "testdata/import/31_import_priority_definitions.yaml",
+			srcPlanPath:  "testdata/import/18_import_src-plan.json",
+			wantUpPath:   "testdata/import/18_import_up.sh",
+			wantDownPath: "testdata/import/18_import_down.sh",
+		},
+		{
+			name:         "import resources with HCL definitions",
+			resDefs:      "testdata/import/31_import_priority_definitions.hcl",
+			srcPlanPath:  "testdata/import/18_import_src-plan.json",
+			wantUpPath:   "testdata/import/18_import_up.sh",
+			wantDownPath: "testdata/import/18_import_down.sh",
+		},
+		{
+			name:         "import resources with built-in definitions and overrides",
+			options:      []string{"--res-defs", "testdata/import/20_import_builtin_override_definitions.json"},
+			resDefs:      "builtin:github",
+			srcPlanPath:  "testdata/import/18_import_src-plan.json",
+			wantUpPath:   "testdata/import/20_import_up.sh",
+			wantDownPath: "testdata/import/20_import_down.sh",
+		},
+		{
+			name:         "import resources ordered by dependencies",
+			options:      []string{"--order-by-deps"},
+			resDefs:      "testdata/import/19_import_deps_definitions.json",
+			srcPlanPath:  "testdata/import/18_import_src-plan.json",
+			wantUpPath:   "testdata/import/18_import_up.sh",
+			wantDownPath: "testdata/import/18_import_down.sh",
+		},
+		{
+			name:         "import only resources to create, not to replace",
+			resDefs:      "testdata/import/terravalet_imports_definitions.json",
+			srcPlanPath:  "testdata/import/22_import_src-plan_replace.json",
+			wantUpPath:   "testdata/import/22_import_up.sh",
+			wantDownPath: "testdata/import/22_import_down.sh",
+		},
+		{
+			name:         "import resources with attribute values unknown in the plan",
+			options:      []string{"--attr-values", "testdata/import/26_import_attr_values.json"},
+			resDefs:      "testdata/import/26_import_unknown_definitions.json",
+			srcPlanPath:  "testdata/import/26_import_src-plan_unknown.json",
+			wantUpPath:   "testdata/import/26_import_up.sh",
+			wantDownPath: "testdata/import/26_import_down.sh",
+		},
+		{
+			name:         "import resources with address rules",
+			resDefs:      "testdata/import/30_import_address_rules_definitions.json",
+			srcPlanPath:  "testdata/import/30_import_src-plan_modules.json",
+			wantUpPath:   "testdata/import/30_import_up.sh",
+			wantDownPath: "testdata/import/30_import_down.sh",
+		},
+		{
+			name:         "import resources with IDs from an inventory",
+			options:      []string{"--ids", "testdata/import/28_import_ids.csv"},
+			resDefs:      "testdata/import/terravalet_imports_definitions.json",
+			srcPlanPath:  "testdata/import/26_import_src-plan_unknown.json",
+			wantUpPath:   "testdata/import/26_import_up.sh",
+			wantDownPath: "testdata/import/26_import_down.sh",
+		},
+		{
+			name: "import resources filtered by type and address",
+			options: []string{
+				"--type", "github_repository", "--type", "github_branch_default",
+				"--exclude", `module.github.*["test-import-gh"]`,
+			},
+			resDefs:      "testdata/import/terravalet_imports_definitions.json",
+			srcPlanPath:  "testdata/import/08_import_src-plan.json",
+			wantUpPath:   "testdata/import/32_import_filter_up.sh",
+			wantDownPath: "testdata/import/32_import_filter_down.sh",
+		},
+		{
+			name:         "import resources of aliased providers",
+			resDefs:      "testdata/import/33_import_provider_alias_definitions.json",
+			srcPlanPath:  "testdata/import/33_import_src-plan_provider_alias.json",
+			wantUpPath:   "testdata/import/33_import_up.sh",
+			wantDownPath: "testdata/import/33_import_down.sh",
+		},
+		{
+			name:         "import resources of aliased providers with -provider",
+			options:      []string{"--import-provider-flag"},
+			resDefs:      "testdata/import/33_import_provider_alias_definitions.json",
+			srcPlanPath:  "testdata/import/33_import_src-plan_provider_alias.json",
+			wantUpPath:   "testdata/import/33_import_provider_flag_up.sh",
+			wantDownPath: "testdata/import/33_import_down.sh",
+		},
+		{
+			name: "import resources with terraform options",
+			options: []string{
+				"--tf-arg=-var-file=env/prod.tfvars", "--tf-arg=-chdir=roots/prod",
+				"--tf-arg=-var=owner=O'Brien team", "--import-provider-flag",
+			},
+			resDefs:      "testdata/import/33_import_provider_alias_definitions.json",
+			srcPlanPath:  "testdata/import/33_import_src-plan_provider_alias.json",
+			wantUpPath:   "testdata/import/34_import_tf_args_up.sh",
+			wantDownPath: "testdata/import/34_import_tf_args_down.sh",
+		},
 	}
 
 	for _, tc := range testCases {
@@ -25,6 +155,7 @@ func TestRunImportSuccess(t *testing.T) {
 				"--res-defs", tc.resDefs,
 				"--src-plan", tc.srcPlanPath,
 			}
+			args = append(args, tc.options...)
 
 			runSuccess(t, args, tc.wantUpPath, tc.wantDownPath)
 		})
@@ -34,6 +165,7 @@ func TestRunImportSuccess(t *testing.T) {
 func TestRunImportFailure(t *testing.T) {
 	testCases := []struct {
 		name        string
+		options     []string
 		resDefs     string
 		srcPlanPath string
 		wantErr     string
@@ -56,6 +188,26 @@ func TestRunImportFailure(t *testing.T) {
 			srcPlanPath: "testdata/import/10_import_no-new-resources.json",
 			wantErr:     "parse src-plan: src-plan doesn't contains resources to create",
 		},
+		{
+			name:        "src-plan contains only resources to replace",
+			resDefs:     "testdata/import/terravalet_imports_definitions.json",
+			srcPlanPath: "testdata/import/23_import_src-plan_only_replace.json",
+			wantErr: "parse src-plan: src-plan doesn't contains resources to create " +
+				"(only 1 resources to replace)",
+		},
+		{
+			name:        "src-plan contains a change without actions",
+			resDefs:     "testdata/import/terravalet_imports_definitions.json",
+			srcPlanPath: "testdata/import/24_import_src-plan_no_actions.json",
+			wantErr:     "parse src-plan: resource dummy_resource1.foo: change has no actions",
+		},
+		{
+			name:        "src-plan contains an after that is not an object",
+			resDefs:     "testdata/import/terravalet_imports_definitions.json",
+			srcPlanPath: "testdata/import/25_import_src-plan_after_not_object.json",
+			wantErr: "parse src-plan: resource dummy_resource1.foo: " +
+				"change.after: type is list; want: object",
+		},
 		{
 			name:        "src-plan contains only undefined resources",
 			resDefs:     "testdata/import/terravalet_imports_definitions.json",
@@ -68,6 +220,69 @@ func TestRunImportFailure(t *testing.T) {
 			srcPlanPath: "testdata/import/12_import_src-plan_invalid_resource_param.json",
 			wantErr:     "parse src-plan: error in resources definition dummy_resource2: field 'long_name' doesn't exist in plan",
 		},
+		{
+			name:        "streaming src-plan does not contain attribute values",
+			resDefs:     "testdata/import/terravalet_imports_definitions.json",
+			srcPlanPath: "testdata/import/13_import_src-plan_stream.jsonl",
+			wantErr: "parse src-plan: resource module.github.github_repository.repos[\"test-import-gh\"]: " +
+				"the plan does not contain attribute values (use the output of 'terraform show -json')",
+		},
+		{
+			name:        "id_template refers to a field that doesn't exist in plan",
+			resDefs:     "testdata/import/15_import_id_template_invalid_definitions.json",
+			srcPlanPath: "testdata/import/14_import_src-plan.json",
+			wantErr: "parse src-plan: error in resources definition aws_iam_role: id_template: " +
+				`template: aws_iam_role:1:36: executing "aws_iam_role" at <.missing>: ` +
+				`map has no entry for key "missing"`,
+		},
+		{
+			name:        "id_template and variables are mutually exclusive",
+			resDefs:     "testdata/import/16_import_id_template_and_variables_definitions.json",
+			srcPlanPath: "testdata/import/14_import_src-plan.json",
+			wantErr: "invalid resources definitions:\n" +
+				"testdata/import/16_import_id_template_and_variables_definitions.json:2:3: " +
+				"definition aws_iam_role: id_template and variables are mutually exclusive",
+		},
+		{
+			name:        "id_template refers to an attribute unknown until apply",
+			resDefs:     "testdata/import/26_import_unknown_definitions.json",
+			srcPlanPath: "testdata/import/26_import_src-plan_unknown.json",
+			wantErr: "parse src-plan: error in resources definition aws_iam_role: id_template: " +
+				`template: aws_iam_role:1:15: executing "aws_iam_role" at <.account_id>: ` +
+				`map has no entry for key "account_id" (resource aws_iam_role.deployer: ` +
+				"unknown until apply: account_id, arn, id; supply them with --attr-values)",
+		},
+		{
+			name:        "attribute values refer to a resource not to create",
+			options:     []string{"--attr-values", "testdata/import/27_import_attr_values_typo.json"},
+			resDefs:     "testdata/import/26_import_unknown_definitions.json",
+			srcPlanPath: "testdata/import/26_import_src-plan_unknown.json",
+			wantErr: "parse src-plan: attribute values: resource aws_iam_user.cli " +
+				"is not a resource to create in src-plan",
+		},
+		{
+			name:        "ids inventory glob matches more resources",
+			options:     []string{"--ids", "testdata/import/29_import_ids_glob_too_wide.json"},
+			resDefs:     "testdata/import/terravalet_imports_definitions.json",
+			srcPlanPath: "testdata/import/26_import_src-plan_unknown.json",
+			wantErr: "parse src-plan: ids inventory: glob aws_iam_* matches 2 resources " +
+				"(aws_iam_role.deployer, aws_iam_user.ci); they cannot have the same import ID",
+		},
+		{
+			name:        "order by dependencies requires the configuration",
+			options:     []string{"--order-by-deps"},
+			resDefs:     "testdata/import/terravalet_imports_definitions.json",
+			srcPlanPath: "testdata/import/08_import_src-plan.json",
+			wantErr: "parse src-plan: ordering by dependencies requires the configuration " +
+				"in the plan (use the output of 'terraform show -json')",
+		},
+		{
+			name:        "unknown built-in definitions",
+			resDefs:     "builtin:nonexisting",
+			srcPlanPath: "testdata/import/08_import_src-plan.json",
+			wantErr: `unknown built-in definitions "nonexisting" ` +
+				"(available: aws, azurerm, cloudflare, github, google)",
+		},
 		{
 			name:        "terravalet missing resources definitions file",
 			resDefs:     "testdata/import/missing.file",
@@ -78,7 +293,49 @@ func TestRunImportFailure(t *testing.T) {
 			name:        "terravalet invalid resources definitions file",
 			resDefs:     "testdata/import/invalid_imports_definitions.json",
 			srcPlanPath: "testdata/import/08_import_src-plan.json",
-			wantErr:     "parse src-plan: parsing resources definitions: invalid character '}' after object key",
+			wantErr: "invalid resources definitions:\n" +
+				"testdata/import/invalid_imports_definitions.json:3:1: " +
+				"invalid character '}' after object key",
+		},
+		{
+			name:        "resources definitions file fails strict validation",
+			resDefs:     "testdata/import/21_import_strict_invalid_definitions.json",
+			srcPlanPath: "testdata/import/08_import_src-plan.json",
+			wantErr: "invalid resources definitions:\n" +
+				"testdata/import/21_import_strict_invalid_definitions.json:2:3: " +
+				"definition github_branch_default: missing separator, required with 2 variables\n" +
+				"testdata/import/21_import_strict_invalid_definitions.json:4:5: " +
+				`definition github_branch_default: unknown key "separtor" (did you mean "separator"?)` + "\n" +
+				"testdata/import/21_import_strict_invalid_definitions.json:8:5: " +
+				"definition github_repository: variables is empty\n" +
+				"testdata/import/21_import_strict_invalid_definitions.json:10:3: " +
+				"duplicate definition github_branch_default (first defined at 2:3)",
+		},
+		{
+			name:        "filter matches no resource",
+			options:     []string{"--include", "module.github.github_repo.*"},
+			resDefs:     "testdata/import/terravalet_imports_definitions.json",
+			srcPlanPath: "testdata/import/08_import_src-plan.json",
+			wantErr: "parse src-plan: --include module.github.github_repo.* " +
+				"matches no resource to create in src-plan",
+		},
+		{
+			name: "filters remove all resources",
+			options: []string{
+				"--type", "github_repository", "--exclude", "module.github.github_repository.*",
+			},
+			resDefs:     "testdata/import/terravalet_imports_definitions.json",
+			srcPlanPath: "testdata/import/08_import_src-plan.json",
+			wantErr: "parse src-plan: src-plan doesn't contains resources to create " +
+				"(all 14 removed by the filters)",
+		},
+		{
+			name:        "parallel script with -chdir",
+			options:     []string{"--parallel", "4", "--tf-arg=-chdir=roots/prod"},
+			resDefs:     "testdata/import/terravalet_imports_definitions.json",
+			srcPlanPath: "testdata/import/08_import_src-plan.json",
+			wantErr: "parallel: -chdir is not supported; run the script from " +
+				"the root directory instead",
 		},
 	}
 
@@ -88,8 +345,28 @@ func TestRunImportFailure(t *testing.T) {
 				"--res-defs", tc.resDefs,
 				"--src-plan", tc.srcPlanPath,
 			}
+			args = append(args, tc.options...)
 
 			runFailure(t, args, tc.wantErr)
 		})
 	}
 }
+
+func TestShellQuote(t *testing.T) {
+	testCases := []struct {
+		in   string
+		want string
+	}{
+		{in: "-var-file=env/prod.tfvars", want: "-var-file=env/prod.tfvars"},
+		{in: "-var=name=a b", want: "'-var=name=a b'"},
+		{in: "-var=owner=O'Brien", want: `'-var=owner=O'\''Brien'`},
+		{in: "-var=x=$HOME", want: "'-var=x=$HOME'"},
+		{in: "", want: "''"},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.in, func(t *testing.T) {
+			qt.Assert(t, qt.Equals(shellQuote(tc.in), tc.want))
+		})
+	}
+}
@@ -0,0 +1,133 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strings"
+)
+
+// The variable of the skeleton definition of a type without candidate variables (see
+// initDefinitions). It passes validation, but fails the import until replaced.
+const initPlaceholder = "TODO"
+
+func doImportDefsInit(srcPlanPath, terraformBin string, out io.Writer) error {
+	srcPlanFile, err := openPlan(srcPlanPath, terraformBin)
+	if err != nil {
+		return fmt.Errorf("opening the terraform plan file: %v", err)
+	}
+	defer srcPlanFile.Close()
+
+	configs, err := initDefinitions(srcPlanFile)
+	if err != nil {
+		return fmt.Errorf("parse src-plan: %v", err)
+	}
+
+	var placeholders []string
+	for resType, def := range configs {
+		if len(def.Variables) == 1 && def.Variables[0] == initPlaceholder {
+			placeholders = append(placeholders, resType)
+		}
+	}
+	if len(placeholders) > 0 {
+		fmt.Fprintf(os.Stderr, "warning: no attribute with a string value in every "+
+			"resource of type %s: replace the placeholder variable %q\n",
+			strings.Join(sorted(placeholders), ", "), initPlaceholder)
+	}
+
+	buf, err := json.MarshalIndent(configs, "", "  ")
+	if err != nil {
+		return fmt.Errorf("encoding the definitions: %v", err)
+	}
+	if _, err := fmt.Fprintf(out, "%s\n", buf); err != nil {
+		return fmt.Errorf("writing the definitions: %v", err)
+	}
+	return nil
+}
+
+// initDefinitions returns a skeleton of resource definitions for the plan read from
+// rd: one definition for each type of resource to create, with as candidate
+// variables all the attributes that have a string value in all the resources of
+// that type. The operator is expected to trim the variables. A type without such
+// attributes gets the placeholder variable initPlaceholder instead, so that the
+// skeleton is valid.
+func initDefinitions(rd io.Reader) (map[string]Definitions, error) {
+	resourcesBundle, err := readResourcesBundle(rd)
+	if err != nil {
+		return nil, err
+	}
+
+	// type -> attribute -> number of resources with a string value for attribute.
+	candidates := map[string]map[string]int{}
+	// type -> number of resources.
+	counts := map[string]int{}
+	for _, resource := range resourcesBundle.ResourceChanges {
+		kind, err := resource.kind()
+		if err != nil {
+			return nil, err
+		}
+		if kind != changeCreate {
+			continue
+		}
+		after, err := resource.afterObject()
+		if err != nil {
+			return nil, err
+		}
+		counts[resource.Type]++
+		if candidates[resource.Type] == nil {
+			candidates[resource.Type] = map[string]int{}
+		}
+		for attr, val := range after {
+			if s, ok := val.(string); ok && s != "" {
+				candidates[resource.Type][attr]++
+			}
+		}
+	}
+	if len(counts) == 0 {
+		return nil, fmt.Errorf("src-plan doesn't contains resources to create")
+	}
+
+	configs := map[string]Definitions{}
+	for resType, attrs := range candidates {
+		var variables []string
+		for attr, n := range attrs {
+			if n == counts[resType] {
+				variables = append(variables, attr)
+			}
+		}
+		sort.Strings(variables)
+		if len(variables) == 0 {
+			variables = []string{initPlaceholder}
+		}
+		def := Definitions{Variables: variables}
+		if len(variables) > 1 {
+			def.Separator = ":"
+		}
+		configs[resType] = def
+	}
+	return configs, nil
+}
+
+// doImportDefsValidate strictly validates the resource definitions sources and
+// reports all the problems found in all of them. It prints nothing on success, so
+// that it can be used as a pre-commit hook.
+func doImportDefsValidate(sources []string) error {
+	var errs []error
+	for _, source := range sources {
+		data, err := readDefinitions(source)
+		if err != nil {
+			errs = append(errs, err)
+			continue
+		}
+		if _, err := decodeDefinitions(source, data); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	if len(errs) > 0 {
+		return fmt.Errorf("invalid resources definitions:\n%s", errors.Join(errs...))
+	}
+	return nil
+}
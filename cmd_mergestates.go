@@ -0,0 +1,192 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+)
+
+// doMergeStates merges into the Terraform state at statePath the resource instances
+// of the states at srcPaths that are not in it. It is used by the parallel import
+// script (see importParallelUpScript), where each worker imports into its own copy
+// of the state.
+func doMergeStates(statePath string, srcPaths []string) error {
+	state, err := readState(statePath)
+	if err != nil {
+		return err
+	}
+	srcs := make([]map[string]interface{}, 0, len(srcPaths))
+	for _, path := range srcPaths {
+		src, err := readState(path)
+		if err != nil {
+			return err
+		}
+		srcs = append(srcs, src)
+	}
+
+	if err := mergeStates(state, srcs, srcPaths); err != nil {
+		return fmt.Errorf("merging states into %s: %s", statePath, err)
+	}
+
+	data, err := json.MarshalIndent(state, "", "  ")
+	if err != nil {
+		return fmt.Errorf("encoding state %s: %s", statePath, err)
+	}
+	// Replace the state atomically, to never leave a truncated state behind.
+	tmp, err := os.CreateTemp(filepath.Dir(statePath), filepath.Base(statePath)+".*")
+	if err != nil {
+		return fmt.Errorf("writing state %s: %s", statePath, err)
+	}
+	defer os.Remove(tmp.Name())
+	if _, err := tmp.Write(append(data, '\n')); err != nil {
+		tmp.Close()
+		return fmt.Errorf("writing state %s: %s", statePath, err)
+	}
+	if err := tmp.Close(); err != nil {
+		return fmt.Errorf("writing state %s: %s", statePath, err)
+	}
+	if err := os.Rename(tmp.Name(), statePath); err != nil {
+		return fmt.Errorf("writing state %s: %s", statePath, err)
+	}
+	return nil
+}
+
+// readState reads the Terraform state at path. The state is kept generic, so that
+// the fields we don't know about survive the merge.
+func readState(path string) (map[string]interface{}, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("opening state: %s", err)
+	}
+	var state map[string]interface{}
+	if err := unmarshalUseNumber(data, &state); err != nil {
+		return nil, fmt.Errorf("parsing state %s: %s", path, err)
+	}
+	if version := fmt.Sprint(state["version"]); version != "4" {
+		return nil, fmt.Errorf("state %s: version is %s; want: 4", path, version)
+	}
+	return state, nil
+}
+
+// mergeStates adds to state the resource instances of srcs (read from srcPaths) that
+// are not in state, and increments the serial of state if it changed. All the states
+// must have the same lineage, since srcs are expected to be copies of state. It is an
+// error if two srcs add the same resource instance.
+func mergeStates(state map[string]interface{}, srcs []map[string]interface{},
+	srcPaths []string,
+) error {
+	// Index the resource instances of state.
+	resources, err := stateResources(state)
+	if err != nil {
+		return err
+	}
+	byKey := map[string]map[string]interface{}{}
+	instances := map[string]string{}
+	for _, res := range resources {
+		key := resourceKey(res)
+		byKey[key] = res
+		for _, inst := range resourceInstances(res) {
+			instances[key+instanceKey(inst)] = "the state"
+		}
+	}
+
+	changed := false
+	for i, src := range srcs {
+		if src["lineage"] != state["lineage"] {
+			return fmt.Errorf("%s: lineage is %v; want: %v (not a copy of the state)",
+				srcPaths[i], src["lineage"], state["lineage"])
+		}
+		srcResources, err := stateResources(src)
+		if err != nil {
+			return fmt.Errorf("%s: %s", srcPaths[i], err)
+		}
+		for _, srcRes := range srcResources {
+			key := resourceKey(srcRes)
+			for _, inst := range resourceInstances(srcRes) {
+				instKey := key + instanceKey(inst)
+				if from, ok := instances[instKey]; ok {
+					if from != "the state" {
+						return fmt.Errorf("%s: resource %s is also in %s", srcPaths[i],
+							instKey, from)
+					}
+					continue
+				}
+				instances[instKey] = srcPaths[i]
+				changed = true
+
+				res, ok := byKey[key]
+				if !ok {
+					// A copy of the resource, without the instances not to merge.
+					res = map[string]interface{}{}
+					for k, v := range srcRes {
+						res[k] = v
+					}
+					res["instances"] = []interface{}{}
+					byKey[key] = res
+					resources = append(resources, res)
+				}
+				res["instances"] = append(resourceInstances(res), inst)
+			}
+		}
+	}
+
+	if changed {
+		list := make([]interface{}, 0, len(resources))
+		for _, res := range resources {
+			list = append(list, res)
+		}
+		state["resources"] = list
+		serial, err := json.Number(fmt.Sprint(state["serial"])).Int64()
+		if err != nil {
+			return fmt.Errorf("serial: %s", err)
+		}
+		state["serial"] = serial + 1
+	}
+	return nil
+}
+
+func stateResources(state map[string]interface{}) ([]map[string]interface{}, error) {
+	list, _ := state["resources"].([]interface{})
+	resources := make([]map[string]interface{}, 0, len(list))
+	for _, elem := range list {
+		res, ok := elem.(map[string]interface{})
+		if !ok {
+			return nil, fmt.Errorf("resources: type is %s; want: object", typeName(elem))
+		}
+		resources = append(resources, res)
+	}
+	return resources, nil
+}
+
+func resourceInstances(res map[string]interface{}) []interface{} {
+	instances, _ := res["instances"].([]interface{})
+	return instances
+}
+
+// resourceKey returns the address of the resource res of the state, for example
+// `module.x["a"].data.foo.bar`.
+func resourceKey(res map[string]interface{}) string {
+	key := ""
+	if module, ok := res["module"].(string); ok && module != "" {
+		key = module + "."
+	}
+	if res["mode"] == "data" {
+		key += "data."
+	}
+	return key + fmt.Sprintf("%v.%v", res["type"], res["name"])
+}
+
+// instanceKey returns the index key of the resource instance inst of the state, in
+// address form: `["a"]`, `[0]` or the empty string.
+func instanceKey(inst interface{}) string {
+	obj, _ := inst.(map[string]interface{})
+	switch key := obj["index_key"].(type) {
+	case nil:
+		return ""
+	case string:
+		return fmt.Sprintf("[%q]", key)
+	default:
+		return fmt.Sprintf("[%v]", key)
+	}
+}
@@ -0,0 +1,99 @@
+package main
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/go-quicktest/qt"
+)
+
+func TestMergeStates(t *testing.T) {
+	const base = `{
+  "version": 4, "serial": 3, "lineage": "L",
+  "resources": [
+    {"mode": "managed", "type": "t", "name": "x", "instances": [{"index_key": 0}]}
+  ]
+}`
+
+	testCases := []struct {
+		name       string
+		srcs       []string
+		wantSerial string
+		wantAddrs  []string
+		wantErr    string
+	}{
+		{
+			name:       "nothing to merge",
+			srcs:       []string{base},
+			wantSerial: "3",
+			wantAddrs:  []string{"t.x[0]"},
+		},
+		{
+			name: "new instances and resources",
+			srcs: []string{
+				`{"version": 4, "serial": 4, "lineage": "L", "resources": [
+				  {"mode": "managed", "type": "t", "name": "x", "instances": [{"index_key": 0}, {"index_key": 1}]}
+				]}`,
+				`{"version": 4, "serial": 4, "lineage": "L", "resources": [
+				  {"module": "module.m[\"a\"]", "mode": "data", "type": "t", "name": "y", "instances": [{}]}
+				]}`,
+			},
+			wantSerial: "4",
+			wantAddrs:  []string{"t.x[0]", "t.x[1]", `module.m["a"].data.t.y`},
+		},
+		{
+			name: "same instance in two sources",
+			srcs: []string{
+				`{"version": 4, "lineage": "L", "resources": [
+				  {"mode": "managed", "type": "t", "name": "z", "instances": [{"index_key": "k"}]}
+				]}`,
+				`{"version": 4, "lineage": "L", "resources": [
+				  {"mode": "managed", "type": "t", "name": "z", "instances": [{"index_key": "k"}]}
+				]}`,
+			},
+			wantErr: `src1: resource t.z\["k"\] is also in src0`,
+		},
+		{
+			name: "different lineage",
+			srcs: []string{
+				`{"version": 4, "lineage": "M", "resources": []}`,
+			},
+			wantErr: `src0: lineage is M; want: L \(not a copy of the state\)`,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			var state map[string]interface{}
+			qt.Assert(t, qt.IsNil(unmarshalUseNumber([]byte(base), &state)))
+			var srcs []map[string]interface{}
+			var paths []string
+			for i, data := range tc.srcs {
+				var src map[string]interface{}
+				qt.Assert(t, qt.IsNil(unmarshalUseNumber([]byte(data), &src)))
+				srcs = append(srcs, src)
+				paths = append(paths, "src"+string(rune('0'+i)))
+			}
+
+			err := mergeStates(state, srcs, paths)
+
+			if tc.wantErr != "" {
+				qt.Assert(t, qt.ErrorMatches(err, tc.wantErr))
+				return
+			}
+			qt.Assert(t, qt.IsNil(err))
+			serial, err := json.Marshal(state["serial"])
+			qt.Assert(t, qt.IsNil(err))
+			qt.Assert(t, qt.Equals(string(serial), tc.wantSerial))
+			resources, err := stateResources(state)
+			qt.Assert(t, qt.IsNil(err))
+			var addrs []string
+			for _, res := range resources {
+				for _, inst := range resourceInstances(res) {
+					addrs = append(addrs, resourceKey(res)+instanceKey(inst))
+				}
+			}
+			qt.Assert(t, qt.DeepEquals(addrs, tc.wantAddrs))
+		})
+	}
+}
@@ -2,6 +2,8 @@ package main
 
 import (
 	"bufio"
+	"bytes"
+	"encoding/json"
 	"fmt"
 	"io"
 	"os"
@@ -14,8 +16,10 @@ import (
 	"github.com/scylladb/go-set/strset"
 )
 
-func doRename(upPath, downPath, planPath, localStatePath string, fuzzyMatch bool) error {
-	planFile, err := os.Open(planPath)
+func doRename(upPath, downPath, planPath, localStatePath string, fuzzyMatch bool,
+	terraformBin string,
+) error {
+	planFile, err := openPlan(planPath, terraformBin)
 	if err != nil {
 		return fmt.Errorf("opening the terraform plan file: %v", err)
 	}
@@ -33,7 +37,7 @@ func doRename(upPath, downPath, planPath, localStatePath string, fuzzyMatch bool
 	}
 	defer downFile.Close()
 
-	create, destroy, err := parse(planFile)
+	create, destroy, moved, err := parse(planFile)
 	if err != nil {
 		return fmt.Errorf("parse: %v", err)
 	}
@@ -61,26 +65,36 @@ func doRename(upPath, downPath, planPath, localStatePath string, fuzzyMatch bool
 
 	stateFlags := "-state=" + localStatePath
 
-	if err := upDownScript(upMatches, stateFlags, upFile); err != nil {
+	if err := upDownScript(upMatches, moved, stateFlags, upFile); err != nil {
 		return fmt.Errorf("writing the up script: %v", err)
 	}
-	if err := upDownScript(downMatches, stateFlags, downFile); err != nil {
+	if err := upDownScript(downMatches, reversed(moved), stateFlags, downFile); err != nil {
 		return fmt.Errorf("writing the down script: %v", err)
 	}
 
 	return nil
 }
 
-func doMoveAfter(script, before, after string) error {
-	beforePlanPath := before + ".tfplan"
-	beforePlanFile, err := os.Open(beforePlanPath)
+func doMoveAfter(script, before, after, beforePlanPath, afterPlanPath,
+	terraformBin string,
+) error {
+	if beforePlanPath == "" {
+		beforePlanPath = before + ".tfplan"
+	}
+	if afterPlanPath == "" {
+		afterPlanPath = after + ".tfplan"
+	}
+	if beforePlanPath == stdinPath && afterPlanPath == stdinPath {
+		return fmt.Errorf("only one of the BEFORE and AFTER plans can be read from stdin")
+	}
+
+	beforePlanFile, err := openPlan(beforePlanPath, terraformBin)
 	if err != nil {
 		return fmt.Errorf("opening the terraform BEFORE plan file: %v", err)
 	}
 	defer beforePlanFile.Close()
 
-	afterPlanPath := after + ".tfplan"
-	afterPlanFile, err := os.Open(afterPlanPath)
+	afterPlanFile, err := openPlan(afterPlanPath, terraformBin)
 	if err != nil {
 		return fmt.Errorf("opening the terraform AFTER plan file: %v", err)
 	}
@@ -100,7 +114,7 @@ func doMoveAfter(script, before, after string) error {
 	}
 	defer downFile.Close()
 
-	beforeCreate, beforeDestroy, err := parse(beforePlanFile)
+	beforeCreate, beforeDestroy, beforeMoved, err := parse(beforePlanFile)
 	if err != nil {
 		return fmt.Errorf("parse BEFORE plan: %v", err)
 	}
@@ -109,7 +123,7 @@ func doMoveAfter(script, before, after string) error {
 			sorted(beforeCreate.List()))
 	}
 
-	afterCreate, afterDestroy, err := parse(afterPlanFile)
+	afterCreate, afterDestroy, afterMoved, err := parse(afterPlanFile)
 	if err != nil {
 		return fmt.Errorf("parse AFTER plan: %v", err)
 	}
@@ -131,19 +145,29 @@ func doMoveAfter(script, before, after string) error {
 	upStateFlags := fmt.Sprintf("-state=%s -state-out=%s", beforeStatePath, afterStatePath)
 	downStateFlags := fmt.Sprintf("-state=%s -state-out=%s", afterStatePath, beforeStatePath)
 
-	if err := upDownScript(upMatches, upStateFlags, upFile); err != nil {
+	moved := make(map[string]string, len(beforeMoved)+len(afterMoved))
+	for from, to := range beforeMoved {
+		moved[from] = to
+	}
+	for from, to := range afterMoved {
+		moved[from] = to
+	}
+
+	if err := upDownScript(upMatches, moved, upStateFlags, upFile); err != nil {
 		return fmt.Errorf("writing the up script: %v", err)
 	}
-	if err := upDownScript(downMatches, downStateFlags, downFile); err != nil {
+	if err := upDownScript(downMatches, reversed(moved), downStateFlags, downFile); err != nil {
 		return fmt.Errorf("writing the down script: %v", err)
 	}
 
 	return nil
 }
 
-func doMoveBefore(script, before, after string) error {
-	beforePlanPath := before + ".tfplan"
-	beforePlanFile, err := os.Open(beforePlanPath)
+func doMoveBefore(script, before, after, beforePlanPath, terraformBin string) error {
+	if beforePlanPath == "" {
+		beforePlanPath = before + ".tfplan"
+	}
+	beforePlanFile, err := openPlan(beforePlanPath, terraformBin)
 	if err != nil {
 		return fmt.Errorf("opening the terraform BEFORE plan file: %v", err)
 	}
@@ -163,7 +187,7 @@ func doMoveBefore(script, before, after string) error {
 	}
 	defer downFile.Close()
 
-	beforeCreate, beforeDestroy, err := parse(beforePlanFile)
+	beforeCreate, beforeDestroy, _, err := parse(beforePlanFile)
 	if err != nil {
 		return fmt.Errorf("parse BEFORE plan: %v", err)
 	}
@@ -183,10 +207,10 @@ func doMoveBefore(script, before, after string) error {
 	upStateFlags := fmt.Sprintf("-state=%s -state-out=%s", afterStatePath, beforeStatePath)
 	downStateFlags := fmt.Sprintf("-state=%s -state-out=%s", beforeStatePath, afterStatePath)
 
-	if err := upDownScript(upMatches, upStateFlags, upFile); err != nil {
+	if err := upDownScript(upMatches, nil, upStateFlags, upFile); err != nil {
 		return fmt.Errorf("writing the up script: %v", err)
 	}
-	if err := upDownScript(downMatches, downStateFlags, downFile); err != nil {
+	if err := upDownScript(downMatches, nil, downStateFlags, downFile); err != nil {
 		return fmt.Errorf("writing the down script: %v", err)
 	}
 
@@ -213,41 +237,152 @@ func collectErrors(create *strset.Set, destroy *strset.Set) string {
 // " # aws_instance.docker will be created"
 // " # module.ci.module.workers["windows-vs2019"].aws_autoscaling_schedule.night_mode will be destroyed"
 // " # module.workers["windows-vs2019"].aws_autoscaling_schedule.night_mode will be created"
-func parse(rd io.Reader) (*strset.Set, *strset.Set, error) {
-	var re = regexp.MustCompile(`# (.+) will be (.+)`)
+//
+// Also return a map old -> new of the elements that Terraform reports as already
+// moved by a "moved" block. These elements are not part of the create and destroy sets.
+//
+// For example:
+// " # aws_instance.foo has moved to aws_instance.bar"
+// " # aws_instance.bar will be updated in-place"
+// " # (moved from aws_instance.foo)"
+//
+// The plan is expected to be the output of "terraform plan -no-color", but ANSI escape
+// sequences are stripped and decorations before the "#" (for example timestamps added
+// by Terraform Cloud or the diff markers added by Atlantis) are tolerated.
+// A plan that is not empty but contains no recognizable resources is an error.
+//
+// The streaming output of "terraform plan -json" is also accepted; see readStream.
+// The same for the output of "terraform show -json", for example from a binary plan;
+// see openPlan.
+func parse(rd io.Reader) (*strset.Set, *strset.Set, map[string]string, error) {
+	data, err := io.ReadAll(rd)
+	if err != nil {
+		return set.NewStringSet(), set.NewStringSet(), map[string]string{}, err
+	}
+	if isStream(data) {
+		changes, err := readStream(bytes.NewReader(data))
+		if err != nil {
+			return set.NewStringSet(), set.NewStringSet(), map[string]string{}, err
+		}
+		return changeSets(changes)
+	}
+	if isJSONPlan(data) {
+		var bundle ResourcesBundle
+		if err := json.Unmarshal(data, &bundle); err != nil {
+			return set.NewStringSet(), set.NewStringSet(), map[string]string{},
+				fmt.Errorf("parsing the JSON plan: %s", err)
+		}
+		return changeSets(bundle.ResourceChanges)
+	}
+	return parseText(bytes.NewReader(data))
+}
+
+// parseText is parse for the output of "terraform plan -no-color".
+func parseText(rd io.Reader) (*strset.Set, *strset.Set, map[string]string, error) {
+	var re = regexp.MustCompile(`(?:^|[\s+~-])# (.+) will be (.+?)\s*$`)
+	var reMoved = regexp.MustCompile(`(?:^|[\s+~-])# (.+) has moved to (.+?)\s*$`)
+	var reMovedFrom = regexp.MustCompile(`(?:^|[\s+~-])# \(moved from (.+)\)\s*$`)
 
 	create := set.NewStringSet()
 	destroy := set.NewStringSet()
+	moved := map[string]string{}
+	// The address of the last resource header ("will be", "must be replaced"), needed
+	// to interpret a following "(moved from ...)" line. Empty after a header that
+	// cannot be followed by one ("has moved to").
+	last := ""
+	// The lines of the elements to update in-place. An update is accepted only for
+	// an element moved by a "moved" block, that is followed by "(moved from ...)".
+	updated := map[string]string{}
+	// Used to detect a plan in an unexpected format.
+	nonBlank := 0
+	recognized := 0
+	noChanges := false
 
 	scanner := bufio.NewScanner(rd)
 	for scanner.Scan() {
-		line := scanner.Text()
+		line := reANSI.ReplaceAllString(scanner.Text(), "")
+		if strings.TrimSpace(line) == "" {
+			continue
+		}
+		nonBlank++
+		if strings.Contains(line, "No changes.") {
+			noChanges = true
+			continue
+		}
+		if m := reMoved.FindStringSubmatch(line); m != nil {
+			recognized++
+			moved[m[1]] = m[2]
+			last = ""
+			continue
+		}
+		if m := reMovedFrom.FindStringSubmatch(line); m != nil {
+			recognized++
+			if last == "" {
+				return create, destroy, moved,
+					fmt.Errorf("line %q: moved from without a resource", line)
+			}
+			moved[m[1]] = last
+			delete(updated, last)
+			continue
+		}
+		if m := reTextReplace.FindStringSubmatch(line); m != nil {
+			// Neither to create nor to destroy, but it can have moved.
+			recognized++
+			last = m[1]
+			continue
+		}
 		if m := re.FindStringSubmatch(line); m != nil {
 			if len(m) != 3 {
-				return create, destroy,
+				return create, destroy, moved,
 					fmt.Errorf("could not parse line %q: %q", line, m)
 			}
+			recognized++
+			last = m[1]
 			switch m[2] {
 			case "created":
 				create.Add(m[1])
 			case "destroyed":
 				destroy.Add(m[1])
+			case "updated in-place":
+				updated[m[1]] = line
 			case "read during apply":
 				// do nothing
 			default:
-				return create, destroy,
+				return create, destroy, moved,
 					fmt.Errorf("line %q, unexpected action %q", line, m[2])
 			}
 		}
 	}
 
 	if err := scanner.Err(); err != nil {
-		return create, destroy, err
+		return create, destroy, moved, err
+	}
+	if nonBlank > 0 && recognized == 0 && !noChanges {
+		return create, destroy, moved,
+			fmt.Errorf("plan is not empty but contains no recognizable resources " +
+				"(is it the output of 'terraform plan -no-color'?)")
+	}
+	if len(updated) > 0 {
+		addrs := make([]string, 0, len(updated))
+		for addr := range updated {
+			addrs = append(addrs, addr)
+		}
+		return create, destroy, moved, fmt.Errorf("line %q, unexpected action %q",
+			updated[sorted(addrs)[0]], "updated in-place")
+	}
+
+	// Elements handled by moved blocks must not take part in the matching.
+	for from, to := range moved {
+		destroy.Remove(from)
+		create.Remove(to)
 	}
 
-	return create, destroy, nil
+	return create, destroy, moved, nil
 }
 
+// Matches an ANSI escape sequence, as emitted by "terraform plan" without -no-color.
+var reANSI = regexp.MustCompile(`\x1b\[[0-9;]*[A-Za-z]`)
+
 // Given two unordered sets create and destroy, perform an exact match from destroy to create.
 //
 // Return two maps, the first that exact matches each old element in destroy to the
@@ -352,14 +487,40 @@ func matchFuzzy(create, destroy *strset.Set) (map[string]string, map[string]stri
 	return upMatches, downMatches, nil
 }
 
+// reversed returns the map new->old of the map old->new m.
+func reversed(m map[string]string) map[string]string {
+	rev := make(map[string]string, len(m))
+	for from, to := range m {
+		rev[to] = from
+	}
+	return rev
+}
+
 // Given a map old->new, create a script that for each element in the map issues the
 // command: "terraform state mv old new".
-func upDownScript(matches map[string]string, stateFlags string, out io.Writer) error {
+// The elements in map moved (old->new), already handled by Terraform moved blocks,
+// are only listed in the script header, for the benefit of the reviewer.
+func upDownScript(matches map[string]string, moved map[string]string, stateFlags string,
+	out io.Writer,
+) error {
 	fmt.Fprintf(out, "#! /bin/sh\n")
 	fmt.Fprintf(out, "# DO NOT EDIT. Generated by terravalet.\n")
 	fmt.Fprintf(out, "# terravalet_output_format=2\n")
 	fmt.Fprintf(out, "#\n")
-	fmt.Fprintf(out, "# This script will move %d items.\n\n", len(matches))
+	fmt.Fprintf(out, "# This script will move %d items.\n", len(matches))
+	if len(moved) > 0 {
+		fmt.Fprintf(out, "#\n")
+		fmt.Fprintf(out, "# Already handled by moved blocks (%d items):\n", len(moved))
+		olds := make([]string, 0, len(moved))
+		for old := range moved {
+			olds = append(olds, old)
+		}
+		sort.Strings(olds)
+		for _, old := range olds {
+			fmt.Fprintf(out, "#   '%s' -> '%s'\n", old, moved[old])
+		}
+	}
+	fmt.Fprintf(out, "\n")
 	fmt.Fprintf(out, "set -e\n\n")
 
 	// -lock=false greatly speeds up operations when the state has many elements
@@ -48,6 +48,13 @@ func TestRunRenameSuccess(t *testing.T) {
 			wantUpPath:   "testdata/rename/07_fuzzy-match.up.sh",
 			wantDownPath: "testdata/rename/07_fuzzy-match.down.sh",
 		},
+		{
+			name:         "exact match mixed with moved blocks",
+			options:      []string{},
+			planPath:     "testdata/rename/08_moved-blocks.plan.txt",
+			wantUpPath:   "testdata/rename/08_moved-blocks.up.sh",
+			wantDownPath: "testdata/rename/08_moved-blocks.down.sh",
+		},
 	}
 
 	for _, tc := range testCases {
@@ -108,6 +115,12 @@ func TestRunMoveAfterSuccess(t *testing.T) {
 			after:      "testdata/move-after/04-after",
 			wantScript: "testdata/move-after/04-want",
 		},
+		{
+			name:       "exact match mixed with moved blocks in both plans",
+			before:     "testdata/move-after/07-before",
+			after:      "testdata/move-after/07-after",
+			wantScript: "testdata/move-after/07-want",
+		},
 	}
 
 	for _, tc := range testCases {
@@ -150,6 +163,13 @@ func TestRunMoveAfterFailure(t *testing.T) {
 			after:   "testdata/move-after/06-after",
 			wantErr: "AFTER plan contains resources to destroy: [aws_batch_job_definition.foo]",
 		},
+		{
+			name:   "update in-place without moved block",
+			before: "testdata/move-after/08-before",
+			after:  "testdata/move-after/08-after",
+			wantErr: `parse BEFORE plan: line "  # aws_batch_job_queue.foo will be updated in-place", ` +
+				`unexpected action "updated in-place"`,
+		},
 	}
 
 	for _, tc := range testCases {
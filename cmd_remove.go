@@ -7,8 +7,8 @@ import (
 	"strings"
 )
 
-func doRemove(planPath string, upPath string) error {
-	planFile, err := os.Open(planPath)
+func doRemove(planPath string, upPath string, terraformBin string) error {
+	planFile, err := openPlan(planPath, terraformBin)
 	if err != nil {
 		return fmt.Errorf("remove: opening the plan file: %s", err)
 	}
@@ -20,7 +20,7 @@ func doRemove(planPath string, upPath string) error {
 	}
 	defer upFile.Close()
 
-	toCreate, toDestroy, err := parse(planFile)
+	toCreate, toDestroy, _, err := parse(planFile)
 	if err != nil {
 		return fmt.Errorf("remove: parsing plan: %s", err)
 	}
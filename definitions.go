@@ -0,0 +1,352 @@
+package main
+
+import (
+	"embed"
+	"fmt"
+	"os"
+	"path"
+	"sort"
+	"strings"
+	"text/template"
+)
+
+type Definitions struct {
+	Separator  string   `json:"separator,omitempty"`
+	Priority   int      `json:"priority,omitempty"`
+	Variables  []string `json:"variables,omitempty"`
+	IDTemplate string   `json:"id_template,omitempty"`
+
+	// Compiled from IDTemplate.
+	idTemplate *template.Template
+}
+
+// Functions available in the id_template of a resource definition. The string to
+// operate on is the last argument, so that they can be used in a pipeline:
+//
+//	{{.branch | trimPrefix "refs/heads/" | lower}}
+var idTemplateFuncs = template.FuncMap{
+	"lower": strings.ToLower,
+	"upper": strings.ToUpper,
+	"trimPrefix": func(prefix, s string) string {
+		return strings.TrimPrefix(s, prefix)
+	},
+	"trimSuffix": func(suffix, s string) string {
+		return strings.TrimSuffix(s, suffix)
+	},
+	"replace": func(old, new, s string) string {
+		return strings.ReplaceAll(s, old, new)
+	},
+	"join": func(sep string, elems []interface{}) (string, error) {
+		strs := make([]string, 0, len(elems))
+		for i, elem := range elems {
+			switch elem.(type) {
+			case withheldValue:
+				return "", fmt.Errorf("join: element %d is unknown until apply or sensitive", i)
+			case nullValue:
+				return "", fmt.Errorf("join: element %d is null", i)
+			}
+			strs = append(strs, fmt.Sprint(elem))
+		}
+		return strings.Join(strs, sep), nil
+	},
+	// Placeholder, bound for each resource by importID on a clone of the compiled
+	// template, which is shared. See lookupField.
+	"attr": func(field string) (string, error) {
+		return "", fmt.Errorf("internal error: attr not bound")
+	},
+}
+
+// The built-in resource definitions, one file per provider. They are versioned
+// together with terravalet.
+//
+//go:embed definitions/*.json
+var builtinDefinitions embed.FS
+
+// Prefix of a resource definitions source that refers to the built-in definitions.
+const builtinPrefix = "builtin:"
+
+// builtinNames returns the sorted names of the built-in definitions, for example
+// "github".
+func builtinNames() []string {
+	entries, _ := builtinDefinitions.ReadDir("definitions")
+	names := make([]string, 0, len(entries))
+	for _, entry := range entries {
+		names = append(names, strings.TrimSuffix(entry.Name(), ".json"))
+	}
+	sort.Strings(names)
+	return names
+}
+
+// loadDefinitions loads the resource definitions from sources, in order. A source is
+// either a path to a definitions file or "builtin:NAME", for the built-in definitions
+// of provider NAME. The definitions of a later source override (for the same
+// resource type) the definitions of an earlier source.
+//
+// Each source is strictly validated, see decodeDefinitions.
+func loadDefinitions(sources []string) (map[string]Definitions, error) {
+	configs := map[string]Definitions{}
+	for _, source := range sources {
+		data, err := readDefinitions(source)
+		if err != nil {
+			return nil, err
+		}
+		defs, err := decodeDefinitions(source, data)
+		if err != nil {
+			return nil, fmt.Errorf("invalid resources definitions:\n%s", err)
+		}
+		for resType, def := range defs {
+			if err := def.compile(resType); err != nil {
+				return nil, err
+			}
+			configs[resType] = def
+		}
+	}
+	return configs, nil
+}
+
+// isAddressRule reports whether key, a key of the resource definitions, is an address
+// rule (a resource address or address glob, see addrGlob) instead of a resource type.
+// Resource types never contain a dot, addresses always do.
+func isAddressRule(key string) bool {
+	return strings.Contains(key, ".")
+}
+
+// definitionFor returns the definition that applies to the resource with address
+// addr and type resType, and its key in configs. Address rules take precedence over
+// the resource type; among the address rules matching addr, the most specific one
+// wins (see addrGlob.specificity), so that an exact address always wins. It returns
+// false if no definition applies.
+func definitionFor(configs map[string]Definitions, addr, resType string,
+) (Definitions, string, bool, error) {
+	if def, ok := configs[addr]; ok {
+		return def, addr, true, nil
+	}
+	keys := make([]string, 0, len(configs))
+	for key := range configs {
+		if isAddressRule(key) {
+			keys = append(keys, key)
+		}
+	}
+	// A tie is an error only if no more specific rule matches.
+	best, tied, bestSpec := "", "", -1
+	for _, key := range sorted(keys) {
+		glob := newAddrGlob(key)
+		if !glob.match(addr) {
+			continue
+		}
+		switch spec := glob.specificity(); {
+		case spec > bestSpec:
+			best, tied, bestSpec = key, "", spec
+		case spec == bestSpec && tied == "":
+			tied = key
+		}
+	}
+	if tied != "" {
+		return Definitions{}, "", false, fmt.Errorf("resource %s: ambiguous "+
+			"resources definitions %s and %s", addr, best, tied)
+	}
+	if best != "" {
+		return configs[best], best, true, nil
+	}
+	def, ok := configs[resType]
+	return def, resType, ok, nil
+}
+
+// readDefinitions returns the contents of the resource definitions source, either a
+// path to a definitions file or "builtin:NAME".
+func readDefinitions(source string) ([]byte, error) {
+	if name, ok := strings.CutPrefix(source, builtinPrefix); ok {
+		data, err := builtinDefinitions.ReadFile(path.Join("definitions", name+".json"))
+		if err != nil {
+			return nil, fmt.Errorf("unknown built-in definitions %q (available: %s)",
+				name, strings.Join(builtinNames(), ", "))
+		}
+		return data, nil
+	}
+	data, err := os.ReadFile(source)
+	if err != nil {
+		return nil, fmt.Errorf("opening the definitions file: %v", err)
+	}
+	return data, nil
+}
+
+// compile validates def and compiles its id_template, if any.
+func (def *Definitions) compile(resType string) error {
+	if def.IDTemplate == "" {
+		return nil
+	}
+	if len(def.Variables) > 0 {
+		return fmt.Errorf("error in resources definition %s: "+
+			"id_template and variables are mutually exclusive", resType)
+	}
+	var err error
+	def.idTemplate, err = template.New(resType).Option("missingkey=error").
+		Funcs(idTemplateFuncs).Parse(def.IDTemplate)
+	if err != nil {
+		return fmt.Errorf("error in resources definition %s: id_template: %s", resType, err)
+	}
+	return nil
+}
+
+// importID returns the import ID of resource, with attributes after and the values
+// supplied by the operator, built according to the definition: either by executing
+// the id_template or by joining the variables with the separator. With variables,
+// it returns also the components of the ID, one per variable (see checkImportID).
+// See lookupField for the syntax of the variables and the use of supplied and
+// states.
+func (def Definitions) importID(resource ResourceChange, after map[string]interface{},
+	supplied map[string]string, states lookupStates,
+) (string, []string, error) {
+	if def.idTemplate != nil {
+		attr := func(field string) (string, error) {
+			return lookupField(resource, after, supplied, states, field)
+		}
+		tmpl, err := def.idTemplate.Clone()
+		if err != nil {
+			return "", nil, fmt.Errorf("error in resources definition %s: id_template: %s",
+				resource.Type, err)
+		}
+		var bld strings.Builder
+		err = tmpl.Funcs(template.FuncMap{"attr": attr}).
+			Execute(&bld, templateData(resource, after, supplied))
+		if err != nil {
+			return "", nil, fmt.Errorf("error in resources definition %s: id_template: %s%s",
+				resource.Type, err, withheldHint(resource, supplied))
+		}
+		return bld.String(), nil, nil
+	}
+
+	var resID []string
+	for _, field := range def.Variables {
+		subID, err := lookupField(resource, after, supplied, states, field)
+		if err != nil {
+			return "", nil, err
+		}
+		resID = append(resID, subID)
+	}
+	return strings.Join(resID, def.Separator), resID, nil
+}
+
+// templateData returns the data of the id_template for resource: after, without the
+// attributes unknown until apply or sensitive at any depth, with the null values
+// replaced by nullValue, and with the supplied top-level attributes. The other fields
+// are available with the function attr.
+func templateData(resource ResourceChange, after map[string]interface{},
+	supplied map[string]string,
+) map[string]interface{} {
+	data, _ := templateValue(resource, after, nil).(map[string]interface{})
+	if data == nil {
+		data = make(map[string]interface{}, len(supplied))
+	}
+	for field, val := range supplied {
+		if steps, err := parseFieldPath(field); err == nil && len(steps) == 1 &&
+			!steps[0].isIndex {
+			data[field] = val
+		}
+	}
+	return data
+}
+
+// A value of the id_template data that is unknown until apply or sensitive, in place
+// of a list element (the attributes of an object are left out instead, so that the
+// template fails with a missing key). text/template refuses to print a func, so
+// using it in the ID is an error; in a conditional, it is false.
+type withheldValue func()
+
+// templateValue returns a copy of val, the value at path steps of the after object of
+// resource, with the values unknown until apply or sensitive left out (see
+// templateData).
+func templateValue(resource ResourceChange, val interface{}, steps []pathStep,
+) interface{} {
+	if len(steps) > 0 && (masked(resource.Change.AfterUnknown, steps) ||
+		masked(resource.Change.AfterSensitive, steps)) {
+		return withheldValue(nil)
+	}
+	// Each level gets its own copy of steps, since append may reuse the array.
+	sub := func(step pathStep) []pathStep {
+		return append(append([]pathStep(nil), steps...), step)
+	}
+	switch v := val.(type) {
+	case map[string]interface{}:
+		obj := make(map[string]interface{}, len(v))
+		for key, elem := range v {
+			elem = templateValue(resource, elem, sub(pathStep{key: key}))
+			if !isWithheld(elem) {
+				obj[key] = elem
+			}
+		}
+		return obj
+	case []interface{}:
+		list := make([]interface{}, 0, len(v))
+		for i, elem := range v {
+			list = append(list, templateValue(resource, elem,
+				sub(pathStep{index: i, isIndex: true})))
+		}
+		return list
+	case nil:
+		return nullValue(nil)
+	default:
+		return val
+	}
+}
+
+// A null value of the id_template data. As withheldValue, using it in the ID is an
+// error (instead of "<no value>"), and in a conditional it is false.
+type nullValue func()
+
+func isWithheld(val interface{}) bool {
+	_, ok := val.(withheldValue)
+	return ok
+}
+
+// withheldHint returns a hint listing the attributes of resource, at any depth, that
+// are unknown until apply or sensitive, and not supplied, or the empty string if
+// there are none. It is appended to the errors of id_template, since a missing key
+// there is most probably one of them.
+func withheldHint(resource ResourceChange, supplied map[string]string) string {
+	var parts []string
+	if attrs := maskedAttrs(resource.Change.AfterUnknown, supplied); len(attrs) > 0 {
+		parts = append(parts, "unknown until apply: "+strings.Join(attrs, ", "))
+	}
+	if attrs := maskedAttrs(resource.Change.AfterSensitive, supplied); len(attrs) > 0 {
+		parts = append(parts, "sensitive: "+strings.Join(attrs, ", "))
+	}
+	if len(parts) == 0 {
+		return ""
+	}
+	return fmt.Sprintf(" (resource %s: %s; supply them with --attr-values)",
+		resource.Address, strings.Join(parts, "; "))
+}
+
+// maskedAttrs returns the sorted field references (see parseFieldPath) of the
+// attributes marked in mask (see masked) that are not in supplied. A marked object or
+// list is listed as a whole.
+func maskedAttrs(mask interface{}, supplied map[string]string) []string {
+	var attrs []string
+	var walk func(mask interface{}, field string)
+	walk = func(mask interface{}, field string) {
+		switch m := mask.(type) {
+		case bool:
+			if _, ok := supplied[field]; m && !ok {
+				attrs = append(attrs, field)
+			}
+		case map[string]interface{}:
+			for key, elem := range m {
+				if field == "" {
+					walk(elem, key)
+				} else if strings.ContainsAny(key, ".[") {
+					walk(elem, fmt.Sprintf("%s[%q]", field, key))
+				} else {
+					walk(elem, field+"."+key)
+				}
+			}
+		case []interface{}:
+			for i, elem := range m {
+				walk(elem, fmt.Sprintf("%s[%d]", field, i))
+			}
+		}
+	}
+	walk(mask, "")
+	sort.Strings(attrs)
+	return attrs
+}
@@ -0,0 +1,415 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"path"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/hashicorp/hcl/v2"
+	"github.com/hashicorp/hcl/v2/hclsyntax"
+	ctyjson "github.com/zclconf/go-cty/cty/json"
+	"gopkg.in/yaml.v3"
+)
+
+// The formats of a resource definitions file.
+const (
+	formatJSON = "JSON"
+	formatYAML = "YAML"
+	formatHCL  = "HCL"
+)
+
+// An HCL block header, like `definition "github_repository" {`.
+var reHCLBlock = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_-]*\s+"`)
+
+// definitionsFormat returns the format of the resource definitions data, read from
+// source. The format is detected from the extension (.json, .yaml, .yml, .hcl) or,
+// failing that, from the first line that is not empty nor a comment.
+func definitionsFormat(source string, data []byte) string {
+	switch strings.ToLower(path.Ext(source)) {
+	case ".json":
+		return formatJSON
+	case ".yaml", ".yml":
+		return formatYAML
+	case ".hcl":
+		return formatHCL
+	}
+	for _, line := range strings.Split(string(data), "\n") {
+		line = strings.TrimSpace(line)
+		switch {
+		case line == "" || strings.HasPrefix(line, "#") || strings.HasPrefix(line, "//"):
+			continue
+		case strings.HasPrefix(line, "{"):
+			return formatJSON
+		case reHCLBlock.MatchString(line):
+			return formatHCL
+		default:
+			return formatYAML
+		}
+	}
+	return formatJSON
+}
+
+//
+// JSON
+//
+
+// readJSONDefinitions reads resource definitions in JSON format: an object mapping
+// resource types to definitions.
+func readJSONDefinitions(data []byte) ([]rawDefinition, []defsError) {
+	r := jsonDefsReader{data: data}
+	raws, err := r.read()
+	if err != nil {
+		// A syntax error stops the reading.
+		var synErr *json.SyntaxError
+		if errors.As(err, &synErr) {
+			r.errorf(max(synErr.Offset-1, 0), "%s", err)
+		} else if err == io.EOF || err == io.ErrUnexpectedEOF {
+			r.errorf(int64(len(data)), "unexpected end of JSON input")
+		} else {
+			r.errorf(0, "%s", err)
+		}
+	}
+	return raws, r.errs
+}
+
+type jsonDefsReader struct {
+	data []byte
+	errs []defsError
+}
+
+// errorf records a problem at the first token starting at or after offset.
+func (r *jsonDefsReader) errorf(offset int64, format string, args ...interface{}) {
+	r.errs = append(r.errs, defsError{pos: r.position(offset),
+		msg: fmt.Sprintf(format, args...)})
+}
+
+// position returns the position of the first token starting at or after offset.
+// The offsets returned by json.Decoder.InputOffset point after the previous token,
+// that is before any whitespace and separator.
+func (r *jsonDefsReader) position(offset int64) defsPos {
+	off := int(r.skip(offset))
+	line := 1 + bytes.Count(r.data[:off], []byte("\n"))
+	col := off - bytes.LastIndexByte(r.data[:off], '\n')
+	return defsPos{line: line, col: col}
+}
+
+// skip returns the offset of the first token starting at or after offset.
+func (r *jsonDefsReader) skip(offset int64) int64 {
+	off := min(int(offset), len(r.data))
+	for off < len(r.data) && bytes.IndexByte([]byte(" \t\r\n,:"), r.data[off]) >= 0 {
+		off++
+	}
+	return int64(off)
+}
+
+// read walks the top-level object, mapping each resource type to its definition.
+// It returns only the errors that prevent continuing the walk; all the other
+// problems are recorded with errorf.
+func (r *jsonDefsReader) read() ([]rawDefinition, error) {
+	dec := json.NewDecoder(bytes.NewReader(r.data))
+	dec.UseNumber()
+
+	tok, err := dec.Token()
+	if err != nil {
+		return nil, err
+	}
+	if tok != json.Delim('{') {
+		r.errorf(0, "want an object mapping resource types to definitions")
+		return nil, nil
+	}
+
+	var raws []rawDefinition
+	for dec.More() {
+		keyOffset := dec.InputOffset()
+		tok, err := dec.Token()
+		if err != nil {
+			return raws, err
+		}
+		valueOffset := dec.InputOffset()
+		var value json.RawMessage
+		if err := dec.Decode(&value); err != nil {
+			return raws, err
+		}
+		raws = append(raws, r.readDefinition(tok.(string), value, keyOffset, valueOffset))
+	}
+	if _, err := dec.Token(); err != nil {
+		return raws, err
+	}
+	trailingOffset := dec.InputOffset()
+	if _, err := dec.Token(); err != io.EOF {
+		if err != nil {
+			return raws, err
+		}
+		r.errorf(trailingOffset, "unexpected data after the definitions")
+	}
+	return raws, nil
+}
+
+// readDefinition reads the definition value of key, whose key and value start
+// respectively at keyOffset and valueOffset of the whole data.
+func (r *jsonDefsReader) readDefinition(key string, value []byte,
+	keyOffset, valueOffset int64,
+) rawDefinition {
+	raw := rawDefinition{key: key, pos: r.position(keyOffset)}
+
+	// The offsets of dec are relative to the start of the value.
+	base := r.skip(valueOffset)
+	dec := json.NewDecoder(bytes.NewReader(value))
+	dec.UseNumber()
+	// The outer decoder already checked the syntax.
+	if tok, _ := dec.Token(); tok != json.Delim('{') {
+		var val interface{}
+		_ = json.Unmarshal(value, &val)
+		raw.badType = typeName(val)
+		raw.pos = r.position(base)
+		return raw
+	}
+	for dec.More() {
+		fieldOffset := base + dec.InputOffset()
+		tok, _ := dec.Token()
+		var val interface{}
+		_ = dec.Decode(&val)
+		raw.fields = append(raw.fields, rawField{
+			name:  tok.(string),
+			pos:   r.position(fieldOffset),
+			value: val,
+		})
+	}
+	return raw
+}
+
+//
+// YAML
+//
+
+// An error of the YAML parser, like "yaml: line 3: did not find expected key".
+var reYAMLError = regexp.MustCompile(`^yaml: line (\d+): (.*)$`)
+
+// readYAMLDefinitions reads resource definitions in YAML format, with the same
+// structure as the JSON format.
+func readYAMLDefinitions(data []byte) ([]rawDefinition, []defsError) {
+	var doc yaml.Node
+	if err := yaml.Unmarshal(data, &doc); err != nil {
+		if m := reYAMLError.FindStringSubmatch(err.Error()); m != nil {
+			line, _ := strconv.Atoi(m[1])
+			return nil, []defsError{{pos: defsPos{line: line}, msg: m[2]}}
+		}
+		return nil, []defsError{{pos: defsPos{line: 1}, msg: err.Error()}}
+	}
+	if len(doc.Content) == 0 {
+		// Empty document.
+		return nil, nil
+	}
+
+	root := doc.Content[0]
+	if root.Kind != yaml.MappingNode {
+		return nil, []defsError{{pos: yamlPos(root),
+			msg: "want an object mapping resource types to definitions"}}
+	}
+	var raws []rawDefinition
+	var errs []defsError
+	for i := 0; i+1 < len(root.Content); i += 2 {
+		keyNode, valNode := root.Content[i], yamlResolve(root.Content[i+1])
+		raw := rawDefinition{key: keyNode.Value, pos: yamlPos(keyNode)}
+		if valNode.Kind != yaml.MappingNode {
+			val, _ := yamlValue(valNode)
+			raw.badType = typeName(val)
+			raw.pos = yamlPos(valNode)
+			raws = append(raws, raw)
+			continue
+		}
+		for j := 0; j+1 < len(valNode.Content); j += 2 {
+			fieldNode := valNode.Content[j]
+			val, err := yamlValue(valNode.Content[j+1])
+			if err != nil {
+				errs = append(errs, defsError{pos: yamlPos(fieldNode),
+					msg: fmt.Sprintf("definition %s: %s: %s", raw.key, fieldNode.Value, err)})
+				continue
+			}
+			raw.fields = append(raw.fields, rawField{
+				name:  fieldNode.Value,
+				pos:   yamlPos(fieldNode),
+				value: val,
+			})
+		}
+		raws = append(raws, raw)
+	}
+	return raws, errs
+}
+
+func yamlPos(node *yaml.Node) defsPos {
+	return defsPos{line: node.Line, col: node.Column}
+}
+
+// yamlResolve follows the alias node, if node is one.
+func yamlResolve(node *yaml.Node) *yaml.Node {
+	if node.Kind == yaml.AliasNode && node.Alias != nil {
+		return node.Alias
+	}
+	return node
+}
+
+// yamlValue decodes node to the same types as encoding/json with UseNumber, so that
+// YAML values are validated as JSON ones.
+func yamlValue(node *yaml.Node) (interface{}, error) {
+	var val interface{}
+	if err := node.Decode(&val); err != nil {
+		return nil, err
+	}
+	return normalizeYAML(val), nil
+}
+
+func normalizeYAML(val interface{}) interface{} {
+	switch v := val.(type) {
+	case int:
+		return json.Number(strconv.Itoa(v))
+	case int64:
+		return json.Number(strconv.FormatInt(v, 10))
+	case uint64:
+		return json.Number(strconv.FormatUint(v, 10))
+	case float64:
+		return json.Number(strconv.FormatFloat(v, 'f', -1, 64))
+	case []interface{}:
+		for i, elem := range v {
+			v[i] = normalizeYAML(elem)
+		}
+		return v
+	case map[string]interface{}:
+		for key, elem := range v {
+			v[key] = normalizeYAML(elem)
+		}
+		return v
+	case map[interface{}]interface{}:
+		obj := make(map[string]interface{}, len(v))
+		for key, elem := range v {
+			obj[fmt.Sprint(key)] = normalizeYAML(elem)
+		}
+		return obj
+	default:
+		return v
+	}
+}
+
+//
+// HCL
+//
+
+// The type of the HCL blocks containing a resource definition.
+const hclDefinitionBlock = "definition"
+
+// readHCLDefinitions reads resource definitions in HCL format, one labeled block per
+// resource type or address rule:
+//
+//	definition "github_repository" {
+//	  priority  = 1
+//	  variables = ["name"]
+//	}
+func readHCLDefinitions(filename string, data []byte) ([]rawDefinition, []defsError) {
+	file, diags := hclsyntax.ParseConfig(data, filename, hcl.InitialPos)
+	if diags.HasErrors() {
+		return nil, hclErrors(diags)
+	}
+	body := file.Body.(*hclsyntax.Body)
+
+	var errs []defsError
+	for _, attr := range hclSortedAttributes(body) {
+		errs = append(errs, defsError{pos: hclPos(attr.NameRange.Start),
+			msg: fmt.Sprintf("unexpected attribute %q; want: %s blocks",
+				attr.Name, hclDefinitionBlock)})
+	}
+
+	var raws []rawDefinition
+	for _, block := range body.Blocks {
+		if block.Type != hclDefinitionBlock || len(block.Labels) != 1 {
+			errs = append(errs, defsError{pos: hclPos(block.TypeRange.Start),
+				msg: fmt.Sprintf(`unexpected block %q; want: %s "TYPE" { ... }`,
+					block.Type, hclDefinitionBlock)})
+			continue
+		}
+		raw := rawDefinition{key: block.Labels[0], pos: hclPos(block.LabelRanges[0].Start)}
+		for _, nested := range block.Body.Blocks {
+			errs = append(errs, defsError{pos: hclPos(nested.TypeRange.Start),
+				msg: fmt.Sprintf("definition %s: unexpected block %q", raw.key, nested.Type)})
+		}
+		for _, attr := range hclSortedAttributes(block.Body) {
+			val, err := hclValue(attr)
+			if err != nil {
+				errs = append(errs, err...)
+				raw.undecoded = true
+				continue
+			}
+			raw.fields = append(raw.fields, rawField{
+				name:  attr.Name,
+				pos:   hclPos(attr.NameRange.Start),
+				value: val,
+			})
+		}
+		raws = append(raws, raw)
+	}
+	return raws, errs
+}
+
+func hclPos(pos hcl.Pos) defsPos {
+	return defsPos{line: pos.Line, col: pos.Column}
+}
+
+// hclSortedAttributes returns the attributes of body in source order.
+func hclSortedAttributes(body *hclsyntax.Body) []*hclsyntax.Attribute {
+	attrs := make([]*hclsyntax.Attribute, 0, len(body.Attributes))
+	for _, attr := range body.Attributes {
+		attrs = append(attrs, attr)
+	}
+	sort.Slice(attrs, func(i, j int) bool {
+		return attrs[i].SrcRange.Start.Byte < attrs[j].SrcRange.Start.Byte
+	})
+	return attrs
+}
+
+// hclValue evaluates the expression of attr, without variables nor functions, and
+// converts it to the same types as encoding/json with UseNumber.
+func hclValue(attr *hclsyntax.Attribute) (interface{}, []defsError) {
+	val, diags := attr.Expr.Value(nil)
+	if diags.HasErrors() {
+		return nil, hclErrors(diags)
+	}
+	if val.IsNull() {
+		return nil, nil
+	}
+	buf, err := ctyjson.Marshal(val, val.Type())
+	if err != nil {
+		return nil, []defsError{{pos: hclPos(attr.Expr.Range().Start), msg: err.Error()}}
+	}
+	var generic interface{}
+	if err := unmarshalUseNumber(buf, &generic); err != nil {
+		return nil, []defsError{{pos: hclPos(attr.Expr.Range().Start), msg: err.Error()}}
+	}
+	return generic, nil
+}
+
+func hclErrors(diags hcl.Diagnostics) []defsError {
+	var errs []defsError
+	for _, diag := range diags.Errs() {
+		var d *hcl.Diagnostic
+		if !errors.As(diag, &d) {
+			errs = append(errs, defsError{pos: defsPos{line: 1}, msg: diag.Error()})
+			continue
+		}
+		pos := defsPos{line: 1}
+		if d.Subject != nil {
+			pos = hclPos(d.Subject.Start)
+		}
+		msg := d.Summary
+		if d.Detail != "" {
+			msg += "; " + d.Detail
+		}
+		errs = append(errs, defsError{pos: pos, msg: msg})
+	}
+	return errs
+}
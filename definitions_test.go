@@ -0,0 +1,373 @@
+package main
+
+import (
+	"testing"
+
+	"github.com/go-quicktest/qt"
+	"github.com/google/go-cmp/cmp/cmpopts"
+)
+
+func TestBuiltinDefinitionsAreValid(t *testing.T) {
+	names := builtinNames()
+	qt.Assert(t, qt.DeepEquals(names, []string{"aws", "azurerm", "cloudflare", "github", "google"}))
+
+	for _, name := range names {
+		t.Run(name, func(t *testing.T) {
+			configs, err := loadDefinitions([]string{builtinPrefix + name})
+
+			qt.Assert(t, qt.IsNil(err))
+			qt.Assert(t, qt.Not(qt.HasLen(configs, 0)))
+		})
+	}
+}
+
+func TestDecodeDefinitionsSuccess(t *testing.T) {
+	data := `{
+  "a": {"variables": ["name"]},
+  "b": {"separator": ":", "priority": -2, "variables": ["x", "tags[\"y\"]"]},
+  "c": {"id_template": "{{.name}}"}
+}`
+
+	_, err := decodeDefinitions("defs.json", []byte(data))
+	qt.Assert(t, qt.IsNil(err))
+}
+
+func TestDecodeDefinitionsFailure(t *testing.T) {
+	testCases := []struct {
+		name    string
+		data    string
+		wantErr string
+	}{
+		{
+			name:    "not an object",
+			data:    `["a"]`,
+			wantErr: "defs.json:1:1: want an object mapping resource types to definitions",
+		},
+		{
+			name:    "definition is not an object",
+			data:    "{\n  \"a\": [\"name\"]\n}",
+			wantErr: "defs.json:2:8: definition a: type is list; want: object",
+		},
+		{
+			name:    "truncated",
+			data:    "{\n  \"a\": {\"variables\": [\"name\"]}",
+			wantErr: "defs.json:2:30: unexpected end of JSON input",
+		},
+		{
+			name:    "trailing data",
+			data:    `{"a": {"variables": ["name"]}} {}`,
+			wantErr: "defs.json:1:32: unexpected data after the definitions",
+		},
+		{
+			name:    "unknown key without suggestion",
+			data:    `{"a": {"variables": ["name"], "comment": "x"}}`,
+			wantErr: `defs.json:1:31: definition a: unknown key "comment"`,
+		},
+		{
+			name:    "missing variables",
+			data:    `{"a": {"priority": 1}}`,
+			wantErr: "defs.json:1:2: definition a: missing variables or id_template",
+		},
+		{
+			name: "wrong types",
+			data: `{"a": {"separator": 1, "priority": "1", "variables": "name"}}`,
+			wantErr: "defs.json:1:8: definition a: separator: type is number; want: string\n" +
+				"defs.json:1:24: definition a: priority: type is string; want: integer\n" +
+				"defs.json:1:41: definition a: variables: type is string; want: list",
+		},
+		{
+			name:    "priority is not an integer",
+			data:    `{"a": {"priority": 1.5, "variables": ["name"]}}`,
+			wantErr: "defs.json:1:8: definition a: priority: 1.5 is not an integer",
+		},
+		{
+			name: "invalid variables",
+			data: `{"a": {"separator": ":", "variables": ["name", 2, "tags[x]"]}}`,
+			wantErr: "defs.json:1:26: definition a: variables[1]: type is number; want: string\n" +
+				`defs.json:1:26: definition a: variables[2]: field "tags[x]": invalid list index "x"`,
+		},
+		{
+			name:    "invalid id_template",
+			data:    `{"a": {"id_template": "{{.name"}}`,
+			wantErr: "defs.json:1:8: definition a: id_template: template: a:1: unclosed action",
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			_, err := decodeDefinitions("defs.json", []byte(tc.data))
+
+			qt.Assert(t, qt.IsNotNil(err))
+			qt.Assert(t, qt.Equals(err.Error(), tc.wantErr))
+		})
+	}
+}
+
+func TestDefinitionFor(t *testing.T) {
+	configs := map[string]Definitions{
+		"github_team_repository":                            {Priority: 1},
+		"module.legacy.github_team_repository.*":            {Priority: 2},
+		`module.legacy.github_team_repository.all["infra"]`: {Priority: 3},
+		"module.*.github_team_repository.all":               {Priority: 4},
+		"*.github_team_repository.all":                      {Priority: 5},
+		"module.a.github_bra*":                              {Priority: 6},
+		"*.github_branch.main":                              {Priority: 7},
+		// Tied, but both less specific than the next one.
+		"*.github_membership.*":        {Priority: 8},
+		"module.b.github_mem*":         {Priority: 9},
+		"module.b.github_membership.*": {Priority: 10},
+	}
+
+	testCases := []struct {
+		addr         string
+		resType      string
+		wantKey      string
+		wantPriority int
+		wantDefined  bool
+		wantErr      string
+	}{
+		{
+			addr:         `github_team_repository.all["foo"]`,
+			resType:      "github_team_repository",
+			wantKey:      "github_team_repository",
+			wantPriority: 1,
+			wantDefined:  true,
+		},
+		{
+			addr:         `module.legacy.github_team_repository.all["foo"]`,
+			resType:      "github_team_repository",
+			wantKey:      "module.legacy.github_team_repository.*",
+			wantPriority: 2,
+			wantDefined:  true,
+		},
+		{
+			addr:         `module.legacy.github_team_repository.all["infra"]`,
+			resType:      "github_team_repository",
+			wantKey:      `module.legacy.github_team_repository.all["infra"]`,
+			wantPriority: 3,
+			wantDefined:  true,
+		},
+		{
+			addr:         "module.x.github_team_repository.all",
+			resType:      "github_team_repository",
+			wantKey:      "module.*.github_team_repository.all",
+			wantPriority: 4,
+			wantDefined:  true,
+		},
+		{
+			addr:        "github_branch.other",
+			resType:     "github_branch",
+			wantKey:     "github_branch",
+			wantDefined: false,
+		},
+		{
+			addr:         "module.b.github_membership.a",
+			resType:      "github_membership",
+			wantKey:      "module.b.github_membership.*",
+			wantPriority: 10,
+			wantDefined:  true,
+		},
+		{
+			addr:    "module.a.github_branch.main",
+			resType: "github_branch",
+			wantErr: "resource module.a.github_branch.main: ambiguous resources " +
+				"definitions *.github_branch.main and module.a.github_bra*",
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.addr, func(t *testing.T) {
+			def, key, defined, err := definitionFor(configs, tc.addr, tc.resType)
+
+			if tc.wantErr != "" {
+				qt.Assert(t, qt.IsNotNil(err))
+				qt.Assert(t, qt.Equals(err.Error(), tc.wantErr))
+				return
+			}
+			qt.Assert(t, qt.IsNil(err))
+			qt.Assert(t, qt.Equals(defined, tc.wantDefined))
+			qt.Assert(t, qt.Equals(key, tc.wantKey))
+			qt.Assert(t, qt.Equals(def.Priority, tc.wantPriority))
+		})
+	}
+}
+
+func TestDefinitionsFormat(t *testing.T) {
+	testCases := []struct {
+		source string
+		data   string
+		want   string
+	}{
+		{source: "defs.json", data: "a: b", want: formatJSON},
+		{source: "defs.yaml", data: "{}", want: formatYAML},
+		{source: "defs.YML", data: "{}", want: formatYAML},
+		{source: "defs.hcl", data: "{}", want: formatHCL},
+		{source: "builtin:github", data: "\n  {\n}", want: formatJSON},
+		{source: "defs", data: "# comment\n\ngithub_repository:\n", want: formatYAML},
+		{source: "defs", data: "// comment\ndefinition \"github_repository\" {\n}", want: formatHCL},
+		{source: "defs", data: "", want: formatJSON},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.source, func(t *testing.T) {
+			qt.Assert(t, qt.Equals(definitionsFormat(tc.source, []byte(tc.data)), tc.want))
+		})
+	}
+}
+
+func TestDecodeDefinitionsFormatsAreEquivalent(t *testing.T) {
+	want, err := loadDefinitions([]string{"testdata/import/18_import_priority_definitions.json"})
+	qt.Assert(t, qt.IsNil(err))
+
+	for _, path := range []string{
+		"testdata/import/31_import_priority_definitions.yaml",
+		"testdata/import/31_import_priority_definitions.hcl",
+	} {
+		t.Run(path, func(t *testing.T) {
+			have, err := loadDefinitions([]string{path})
+
+			qt.Assert(t, qt.IsNil(err))
+			qt.Assert(t, qt.CmpEquals(have, want, cmpopts.IgnoreUnexported(Definitions{})))
+		})
+	}
+}
+
+func TestDecodeDefinitionsYAMLAndHCLFailure(t *testing.T) {
+	testCases := []struct {
+		name    string
+		source  string
+		data    string
+		wantErr string
+	}{
+		{
+			name:   "YAML strict validation",
+			source: "defs.yaml",
+			data: "# comment\n" +
+				"a:\n" +
+				"  separtor: \":\"\n" +
+				"  variables: [x, y]\n" +
+				"  priority: 1.5\n" +
+				"a: [x]\n",
+			wantErr: "defs.yaml:2:1: definition a: missing separator, required with 2 variables\n" +
+				`defs.yaml:3:3: definition a: unknown key "separtor" (did you mean "separator"?)` + "\n" +
+				"defs.yaml:5:3: definition a: priority: 1.5 is not an integer\n" +
+				"defs.yaml:6:4: duplicate definition a (first defined at 2:1)\n" +
+				"defs.yaml:6:4: definition a: type is list; want: object",
+		},
+		{
+			name:    "YAML not a mapping",
+			source:  "defs.yaml",
+			data:    "- a\n- b\n",
+			wantErr: "defs.yaml:1:1: want an object mapping resource types to definitions",
+		},
+		{
+			name:    "YAML syntax error",
+			source:  "defs.yaml",
+			data:    "a:\n  variables: [x]\n\tseparator: x\n",
+			wantErr: "defs.yaml:3: found character that cannot start any token",
+		},
+		{
+			name:   "HCL strict validation",
+			source: "defs.hcl",
+			data: "separator = \":\"\n" +
+				"definition \"a\" {\n" +
+				"  variables = []\n" +
+				"  nested {}\n" +
+				"}\n" +
+				"resource \"b\" {}\n",
+			wantErr: `defs.hcl:1:1: unexpected attribute "separator"; want: definition blocks` + "\n" +
+				"defs.hcl:3:3: definition a: variables is empty\n" +
+				`defs.hcl:4:3: definition a: unexpected block "nested"` + "\n" +
+				`defs.hcl:6:1: unexpected block "resource"; want: definition "TYPE" { ... }`,
+		},
+		{
+			name:    "HCL references are not allowed",
+			source:  "defs.hcl",
+			data:    "definition \"a\" {\n  variables = [var.x]\n}\n",
+			wantErr: "defs.hcl:2:16: Variables not allowed; Variables may not be used here.",
+		},
+		{
+			name:    "HCL syntax error",
+			source:  "defs.hcl",
+			data:    "definition \"a\" {\n  variables = [\"x\"\n}\n",
+			wantErr: "defs.hcl:3:1: Missing item separator; Expected a comma to mark the beginning of the next item.",
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			_, err := decodeDefinitions(tc.source, []byte(tc.data))
+
+			qt.Assert(t, qt.IsNotNil(err))
+			qt.Assert(t, qt.Equals(err.Error(), tc.wantErr))
+		})
+	}
+}
+
+func TestImportIDTemplateWithheld(t *testing.T) {
+	var resource ResourceChange
+	qt.Assert(t, qt.IsNil(unmarshalUseNumber([]byte(`{
+  "address": "foo_db.x",
+  "type": "foo_db",
+  "change": {
+    "actions": ["create"],
+    "after": {"obj": {"name": "n", "secret": "s"}, "list": ["a", "b"], "none": null, "nulls": ["a", null]},
+    "after_unknown": {"obj": {"id": true}},
+    "after_sensitive": {"obj": {"secret": true}, "list": [false, true]}
+  }
+}`), &resource)))
+	after, err := resource.afterObject()
+	qt.Assert(t, qt.IsNil(err))
+	const hint = ` \(resource foo_db.x: unknown until apply: obj.id; ` +
+		`sensitive: list\[1\], obj.secret; supply them with --attr-values\)`
+
+	testCases := []struct {
+		idTemplate string
+		want       string
+		wantErr    string
+	}{
+		{idTemplate: "{{.obj.name}}-{{index .list 0}}", want: "n-a"},
+		{
+			idTemplate: "{{.obj.secret}}",
+			wantErr:    `.*map has no entry for key "secret"` + hint,
+		},
+		{
+			idTemplate: "{{.obj.id}}",
+			wantErr:    `.*map has no entry for key "id"` + hint,
+		},
+		{
+			idTemplate: "{{index .list 1}}",
+			wantErr:    `.*can't print \{\{index .list 1\}\} of type main.withheldValue` + hint,
+		},
+		{idTemplate: `{{if index .list 1}}yes{{else}}no{{end}}`, want: "no"},
+		{
+			idTemplate: `{{join "," .list}}`,
+			wantErr:    `.*join: element 1 is unknown until apply or sensitive` + hint,
+		},
+		{
+			idTemplate: "{{.none}}",
+			wantErr:    `.*can't print \{\{.none\}\} of type main.nullValue` + hint,
+		},
+		{
+			idTemplate: `{{join "," .nulls}}`,
+			wantErr:    `.*join: element 1 is null` + hint,
+		},
+		{idTemplate: `{{if .none}}yes{{else}}no{{end}}`, want: "no"},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.idTemplate, func(t *testing.T) {
+			def := Definitions{IDTemplate: tc.idTemplate}
+			qt.Assert(t, qt.IsNil(def.compile("foo_db")))
+
+			have, _, err := def.importID(resource, after, nil, nil)
+
+			if tc.wantErr != "" {
+				qt.Assert(t, qt.ErrorMatches(err, tc.wantErr))
+				return
+			}
+			qt.Assert(t, qt.IsNil(err))
+			qt.Assert(t, qt.Equals(have, tc.want))
+		})
+	}
+}
@@ -0,0 +1,241 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sort"
+	"text/template"
+
+	"github.com/dexyk/stringosim"
+)
+
+// The keys of a resource definition. Keep in sync with Definitions.
+var definitionKeys = []string{"separator", "priority", "variables", "id_template"}
+
+// A position in a definitions file. Column 0 means unknown.
+type defsPos struct {
+	line, col int
+}
+
+// A problem found in a definitions file.
+type defsError struct {
+	pos defsPos
+	msg string
+}
+
+// A resource definition as read from a definitions file, before validation. Each
+// format (see readJSONDefinitions, readYAMLDefinitions, readHCLDefinitions) is read
+// into this common form, so that all formats share the same validation.
+type rawDefinition struct {
+	key string
+	pos defsPos
+	// The type of the definition, if it is not an object (see typeName).
+	badType string
+	fields  []rawField
+	// Some fields could not be decoded (and are not in fields), an error already
+	// reported by the reader: the checks across fields would report spurious errors.
+	undecoded bool
+}
+
+// A field of a resource definition. The value is as decoded by encoding/json with
+// UseNumber: string, json.Number, bool, []interface{}, map[string]interface{} or nil.
+type rawField struct {
+	name  string
+	pos   defsPos
+	value interface{}
+}
+
+// decodeDefinitions decodes and strictly validates the resource definitions data,
+// read from source. The format (JSON, YAML or HCL) is detected by definitionsFormat.
+// It returns all the problems found, sorted by position, one per line, each prefixed
+// by "source:line:column:".
+//
+// Contrary to a plain decoding, it reports unknown keys (a typo like "separtor"
+// would otherwise be silently ignored) and duplicate resource types (the last one
+// would otherwise silently win).
+func decodeDefinitions(source string, data []byte) (map[string]Definitions, error) {
+	var raws []rawDefinition
+	var readErrs []defsError
+	switch definitionsFormat(source, data) {
+	case formatYAML:
+		raws, readErrs = readYAMLDefinitions(data)
+	case formatHCL:
+		raws, readErrs = readHCLDefinitions(source, data)
+	default:
+		raws, readErrs = readJSONDefinitions(data)
+	}
+	defs, errs := checkDefinitions(raws)
+	errs = append(errs, readErrs...)
+	sort.SliceStable(errs, func(i, j int) bool {
+		if errs[i].pos.line != errs[j].pos.line {
+			return errs[i].pos.line < errs[j].pos.line
+		}
+		return errs[i].pos.col < errs[j].pos.col
+	})
+
+	if len(errs) > 0 {
+		joined := make([]error, 0, len(errs))
+		for _, e := range errs {
+			if e.pos.col == 0 {
+				joined = append(joined, fmt.Errorf("%s:%d: %s", source, e.pos.line, e.msg))
+			} else {
+				joined = append(joined, fmt.Errorf("%s:%d:%d: %s", source, e.pos.line,
+					e.pos.col, e.msg))
+			}
+		}
+		return nil, errors.Join(joined...)
+	}
+	return defs, nil
+}
+
+// checkDefinitions validates raws and converts them to Definitions.
+func checkDefinitions(raws []rawDefinition) (map[string]Definitions, []defsError) {
+	var errs []defsError
+	errorf := func(pos defsPos, format string, args ...interface{}) {
+		errs = append(errs, defsError{pos: pos, msg: fmt.Sprintf(format, args...)})
+	}
+
+	defs := map[string]Definitions{}
+	seen := map[string]defsPos{}
+	for _, raw := range raws {
+		resType := raw.key
+		if prev, ok := seen[resType]; ok {
+			errorf(raw.pos, "duplicate definition %s (first defined at %d:%d)",
+				resType, prev.line, prev.col)
+		} else {
+			seen[resType] = raw.pos
+		}
+		if raw.badType != "" {
+			errorf(raw.pos, "definition %s: type is %s; want: object", resType, raw.badType)
+			continue
+		}
+
+		var def Definitions
+		var hasVariables, emptyVariables bool
+		var variablesPos defsPos
+		seenFields := map[string]bool{}
+		for _, field := range raw.fields {
+			if seenFields[field.name] {
+				errorf(field.pos, "definition %s: duplicate key %q", resType, field.name)
+			}
+			seenFields[field.name] = true
+
+			val := field.value
+			switch field.name {
+			case "separator":
+				s, ok := val.(string)
+				if !ok {
+					errorf(field.pos, "definition %s: separator: type is %s; want: string",
+						resType, typeName(val))
+				}
+				def.Separator = s
+			case "priority":
+				n, ok := val.(json.Number)
+				if !ok {
+					errorf(field.pos, "definition %s: priority: type is %s; want: integer",
+						resType, typeName(val))
+					continue
+				}
+				i, err := n.Int64()
+				if err != nil {
+					errorf(field.pos, "definition %s: priority: %s is not an integer",
+						resType, n)
+				}
+				def.Priority = int(i)
+			case "variables":
+				hasVariables = true
+				variablesPos = field.pos
+				list, ok := val.([]interface{})
+				if !ok {
+					errorf(field.pos, "definition %s: variables: type is %s; want: list",
+						resType, typeName(val))
+					continue
+				}
+				emptyVariables = len(list) == 0
+				for i, elem := range list {
+					s, ok := elem.(string)
+					if !ok {
+						errorf(field.pos, "definition %s: variables[%d]: type is %s; want: string",
+							resType, i, typeName(elem))
+						continue
+					}
+					if isStateRef(s) {
+						if _, _, err := parseStateRef(s); err != nil {
+							errorf(field.pos, "definition %s: variables[%d]: %s", resType, i, err)
+						}
+					} else if _, err := parseFieldPath(s); err != nil {
+						errorf(field.pos, "definition %s: variables[%d]: %s", resType, i, err)
+					}
+					def.Variables = append(def.Variables, s)
+				}
+			case "id_template":
+				s, ok := val.(string)
+				if !ok {
+					errorf(field.pos, "definition %s: id_template: type is %s; want: string",
+						resType, typeName(val))
+					continue
+				}
+				def.IDTemplate = s
+				_, err := template.New(resType).Funcs(idTemplateFuncs).Parse(s)
+				if err != nil {
+					errorf(field.pos, "definition %s: id_template: %s", resType, err)
+				}
+			default:
+				msg := fmt.Sprintf("definition %s: unknown key %q", resType, field.name)
+				if suggestion := closestKey(field.name); suggestion != "" {
+					msg += fmt.Sprintf(" (did you mean %q?)", suggestion)
+				}
+				errorf(field.pos, "%s", msg)
+			}
+		}
+
+		switch {
+		case raw.undecoded:
+		case def.IDTemplate != "" && hasVariables:
+			errorf(raw.pos, "definition %s: id_template and variables are mutually exclusive",
+				resType)
+		case def.IDTemplate != "":
+		case emptyVariables:
+			errorf(variablesPos, "definition %s: variables is empty", resType)
+		case !hasVariables:
+			errorf(raw.pos, "definition %s: missing variables or id_template", resType)
+		case len(def.Variables) > 1 && def.Separator == "":
+			errorf(raw.pos, "definition %s: missing separator, required with %d variables",
+				resType, len(def.Variables))
+		}
+		defs[resType] = def
+	}
+	return defs, errs
+}
+
+// closestKey returns the key of a resource definition most similar to key, if it is
+// similar enough to be a typo, or the empty string.
+func closestKey(key string) string {
+	best, bestDist := "", 3
+	for _, candidate := range definitionKeys {
+		dist := stringosim.Levenshtein([]rune(key), []rune(candidate))
+		if dist < bestDist {
+			best, bestDist = candidate, dist
+		}
+	}
+	return best
+}
+
+// typeName returns the name of the JSON type of val, as decoded by encoding/json.
+func typeName(val interface{}) string {
+	switch val.(type) {
+	case string:
+		return "string"
+	case json.Number, float64:
+		return "number"
+	case bool:
+		return "bool"
+	case []interface{}:
+		return "list"
+	case map[string]interface{}:
+		return "object"
+	default:
+		return "null"
+	}
+}
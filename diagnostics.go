@@ -0,0 +1,140 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+	"text/tabwriter"
+)
+
+// A diagnostic: a warning or an information for the operator, that is not part of the
+// output of the command. See diagnostics.
+type diagnostic struct {
+	// "warning" or "info".
+	Severity string `json:"severity"`
+	// Identifies the kind of diagnostic, for tooling: "replaced", "undefined",
+	// "filtered" or "summary".
+	Code    string `json:"code"`
+	Message string `json:"message"`
+	// The resource address and type the diagnostic refers to, if any.
+	Address string `json:"address,omitempty"`
+	Type    string `json:"type,omitempty"`
+	// Counters, by name, for diagnostics that summarize.
+	Counts map[string]int `json:"counts,omitempty"`
+}
+
+// The diagnostics channel of a command. Diagnostics go to a separate stream (stderr),
+// so that they do not mix with the output of the command, either as text for humans
+// or as JSON, one object per line, for tooling.
+type diagnostics struct {
+	out  io.Writer
+	json bool
+}
+
+// newDiagnostics returns a diagnostics channel writing to out in format, either
+// "text" or "json".
+func newDiagnostics(out io.Writer, format string) (*diagnostics, error) {
+	switch format {
+	case "text":
+		return &diagnostics{out: out}, nil
+	case "json":
+		return &diagnostics{out: out, json: true}, nil
+	default:
+		return nil, fmt.Errorf("diagnostics format: %q; want: text or json", format)
+	}
+}
+
+func (d *diagnostics) emit(diag diagnostic) {
+	if d.json {
+		// Cannot fail: all the fields are strings or integers.
+		line, _ := json.Marshal(diag)
+		fmt.Fprintf(d.out, "%s\n", line)
+		return
+	}
+	if diag.Severity == "warning" {
+		fmt.Fprintf(d.out, "\033[1;33mWarning: %s\033[0m\n", diag.Message)
+		return
+	}
+	fmt.Fprintf(d.out, "%s\n", diag.Message)
+}
+
+func (d *diagnostics) warn(code, addr, resType, format string, args ...interface{}) {
+	d.emit(diagnostic{
+		Severity: "warning",
+		Code:     code,
+		Message:  fmt.Sprintf(format, args...),
+		Address:  addr,
+		Type:     resType,
+	})
+}
+
+// The outcome of the import for the resources to create of one type.
+type typeSummary struct {
+	toImport int
+	// Skipped because, respectively: without resources definition, planned for
+	// replacement, removed by the filters.
+	undefined, replaced, filtered int
+}
+
+func (s typeSummary) skipped() int {
+	return s.undefined + s.replaced + s.filtered
+}
+
+// summary emits the summary of the import, by resource type. As text, it is a table;
+// as JSON, one "summary" diagnostic per type.
+func (d *diagnostics) summary(summaries map[string]*typeSummary) {
+	types := make([]string, 0, len(summaries))
+	for resType := range summaries {
+		types = append(types, resType)
+	}
+	sort.Strings(types)
+
+	if d.json {
+		for _, resType := range types {
+			s := summaries[resType]
+			d.emit(diagnostic{
+				Severity: "info",
+				Code:     "summary",
+				Message: fmt.Sprintf("%s: %d to import, %d skipped", resType,
+					s.toImport, s.skipped()),
+				Type: resType,
+				Counts: map[string]int{
+					"to_import": s.toImport,
+					"skipped":   s.skipped(),
+					"undefined": s.undefined,
+					"replaced":  s.replaced,
+					"filtered":  s.filtered,
+				},
+			})
+		}
+		return
+	}
+
+	fmt.Fprintf(d.out, "Summary by resource type:\n")
+	tw := tabwriter.NewWriter(d.out, 0, 0, 2, ' ', 0)
+	fmt.Fprintf(tw, "  TYPE\tTO IMPORT\tSKIPPED\n")
+	for _, resType := range types {
+		s := summaries[resType]
+		var reasons []string
+		for _, r := range []struct {
+			n      int
+			reason string
+		}{
+			{s.undefined, "undefined"},
+			{s.replaced, "replaced"},
+			{s.filtered, "filtered"},
+		} {
+			if r.n > 0 {
+				reasons = append(reasons, fmt.Sprintf("%d %s", r.n, r.reason))
+			}
+		}
+		skipped := fmt.Sprint(s.skipped())
+		if len(reasons) > 0 {
+			skipped += " (" + strings.Join(reasons, ", ") + ")"
+		}
+		fmt.Fprintf(tw, "  %s\t%d\t%s\n", resType, s.toImport, skipped)
+	}
+	tw.Flush()
+}
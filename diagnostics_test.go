@@ -0,0 +1,55 @@
+package main
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/go-quicktest/qt"
+)
+
+func TestDiagnostics(t *testing.T) {
+	summaries := map[string]*typeSummary{
+		"github_repository": {toImport: 2, replaced: 1, filtered: 3},
+		"github_branch":     {toImport: 1},
+	}
+
+	testCases := []struct {
+		format string
+		want   string
+	}{
+		{
+			format: "text",
+			want: "\033[1;33mWarning: resource foo_bar is not defined\033[0m\n" +
+				"Summary by resource type:\n" +
+				"  TYPE               TO IMPORT  SKIPPED\n" +
+				"  github_branch      1          0\n" +
+				"  github_repository  2          4 (1 replaced, 3 filtered)\n",
+		},
+		{
+			format: "json",
+			want: `{"severity":"warning","code":"undefined","message":"resource foo_bar is not defined","address":"foo_bar.x","type":"foo_bar"}` + "\n" +
+				`{"severity":"info","code":"summary","message":"github_branch: 1 to import, 0 skipped","type":"github_branch","counts":{"filtered":0,"replaced":0,"skipped":0,"to_import":1,"undefined":0}}` + "\n" +
+				`{"severity":"info","code":"summary","message":"github_repository: 2 to import, 4 skipped","type":"github_repository","counts":{"filtered":3,"replaced":1,"skipped":4,"to_import":2,"undefined":0}}` + "\n",
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.format, func(t *testing.T) {
+			var out bytes.Buffer
+			diags, err := newDiagnostics(&out, tc.format)
+			qt.Assert(t, qt.IsNil(err))
+
+			diags.warn("undefined", "foo_bar.x", "foo_bar", "resource %s is not defined",
+				"foo_bar")
+			diags.summary(summaries)
+
+			qt.Assert(t, qt.Equals(out.String(), tc.want))
+		})
+	}
+}
+
+func TestNewDiagnosticsFailure(t *testing.T) {
+	_, err := newDiagnostics(&bytes.Buffer{}, "yaml")
+
+	qt.Assert(t, qt.ErrorMatches(err, `diagnostics format: "yaml"; want: text or json`))
+}
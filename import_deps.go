@@ -0,0 +1,187 @@
+package main
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// The "configuration" object of the output of "terraform show -json". We decode only
+// what is needed to know the dependencies between resources and their provider
+// configurations.
+type Configuration struct {
+	RootModule configModule `json:"root_module"`
+}
+
+type configModule struct {
+	Resources []struct {
+		Address     string                 `json:"address"`
+		Expressions map[string]interface{} `json:"expressions"`
+		// For example "aws.us_east_1", or "module.x:aws.us_east_1" for a provider
+		// configuration of module x.
+		ProviderConfigKey string `json:"provider_config_key"`
+	} `json:"resources"`
+	ModuleCalls map[string]struct {
+		Module configModule `json:"module"`
+	} `json:"module_calls"`
+}
+
+// dependencies returns a map from the configuration address of each resource (see
+// configAddress) to the configuration addresses of the resources it refers to.
+// Only references within the same module are considered, since references to
+// other modules go through module outputs and variables.
+func (cfg Configuration) dependencies() map[string][]string {
+	deps := map[string][]string{}
+	cfg.RootModule.dependencies("", deps)
+	return deps
+}
+
+func (mod configModule) dependencies(prefix string, deps map[string][]string) {
+	for _, res := range mod.Resources {
+		addr := prefix + res.Address
+		seen := map[string]bool{}
+		for _, ref := range collectReferences(res.Expressions, nil) {
+			target, ok := referencedResource(ref)
+			if !ok || seen[target] || target == res.Address {
+				continue
+			}
+			seen[target] = true
+			deps[addr] = append(deps[addr], prefix+target)
+		}
+		if _, ok := deps[addr]; !ok {
+			deps[addr] = nil
+		}
+	}
+	for name, call := range mod.ModuleCalls {
+		call.Module.dependencies(prefix+"module."+name+".", deps)
+	}
+}
+
+// providerAliases returns a map from the configuration address of each resource (see
+// configAddress) that uses an aliased provider configuration to that configuration,
+// in the form NAME.ALIAS, as for the -provider option of old versions of "terraform
+// import". A module resource that uses a configuration passed by the root module has
+// its key (for example "aws.frankfurt"); one that uses a configuration declared in
+// its module has the key with the module, for example "module.x:aws.frankfurt",
+// which -provider cannot name (see isModuleProvider).
+func (cfg Configuration) providerAliases() map[string]string {
+	aliases := map[string]string{}
+	cfg.RootModule.providerAliases("", aliases)
+	return aliases
+}
+
+func (mod configModule) providerAliases(prefix string, aliases map[string]string) {
+	for _, res := range mod.Resources {
+		key := res.ProviderConfigKey
+		if i := strings.LastIndex(key, ":"); i >= 0 {
+			key = key[i+1:]
+		}
+		if strings.Contains(key, ".") {
+			aliases[prefix+res.Address] = res.ProviderConfigKey
+		}
+	}
+	for name, call := range mod.ModuleCalls {
+		call.Module.providerAliases(prefix+"module."+name+".", aliases)
+	}
+}
+
+// isModuleProvider reports whether key, a provider configuration key, refers to a
+// configuration declared in a module instead of the root module.
+func isModuleProvider(key string) bool {
+	return strings.Contains(key, ":")
+}
+
+// collectReferences appends to refs all the "references" found, at any depth, in the
+// expressions of a configuration resource. Nested blocks are represented as nested
+// objects or lists of objects.
+func collectReferences(expr interface{}, refs []string) []string {
+	switch v := expr.(type) {
+	case map[string]interface{}:
+		for key, elem := range v {
+			if list, ok := elem.([]interface{}); ok && key == "references" {
+				for _, ref := range list {
+					if s, ok := ref.(string); ok {
+						refs = append(refs, s)
+					}
+				}
+				continue
+			}
+			refs = collectReferences(elem, refs)
+		}
+	case []interface{}:
+		for _, elem := range v {
+			refs = collectReferences(elem, refs)
+		}
+	}
+	return refs
+}
+
+// referencedResource returns the configuration address of the resource referred to
+// by the reference ref, relative to the module. It returns false if ref does not
+// refer to a managed resource, for example `var.x` or `data.foo.bar`.
+//
+// For example `github_repository.repos["foo"].name` refers to
+// `github_repository.repos`.
+func referencedResource(ref string) (string, bool) {
+	steps, err := splitAddress(ref)
+	if err != nil || len(steps) < 2 {
+		return "", false
+	}
+	switch steps[0].name {
+	case "var", "local", "each", "count", "path", "terraform", "self", "module", "data":
+		return "", false
+	}
+	return steps[0].name + "." + steps[1].name, true
+}
+
+// dependencyDepths returns, for each configuration address in deps, the length of
+// the longest chain of dependencies starting from it: 0 for a resource without
+// dependencies, 1 for a resource depending only on resources without dependencies,
+// and so on. Importing in order of increasing depth imports parents before their
+// dependents.
+func dependencyDepths(deps map[string][]string) (map[string]int, error) {
+	depths := map[string]int{}
+	visiting := map[string]bool{}
+
+	var visit func(addr string) (int, error)
+	visit = func(addr string) (int, error) {
+		if depth, ok := depths[addr]; ok {
+			return depth, nil
+		}
+		if visiting[addr] {
+			return 0, fmt.Errorf("dependency cycle involving %s", addr)
+		}
+		visiting[addr] = true
+		depth := 0
+		for _, dep := range deps[addr] {
+			d, err := visit(dep)
+			if err != nil {
+				return 0, err
+			}
+			if d+1 > depth {
+				depth = d + 1
+			}
+		}
+		visiting[addr] = false
+		depths[addr] = depth
+		return depth, nil
+	}
+
+	// Visit in a stable order, to get stable error messages.
+	addrs := make([]string, 0, len(deps))
+	for addr := range deps {
+		addrs = append(addrs, addr)
+	}
+	sort.Strings(addrs)
+	for _, addr := range addrs {
+		if _, err := visit(addr); err != nil {
+			return nil, err
+		}
+	}
+	return depths, nil
+}
+
+// hasConfiguration reports whether cfg contains at least one resource.
+func (cfg Configuration) hasConfiguration() bool {
+	return len(cfg.RootModule.Resources) > 0 || len(cfg.RootModule.ModuleCalls) > 0
+}
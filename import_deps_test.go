@@ -0,0 +1,78 @@
+package main
+
+import (
+	"encoding/json"
+	"os"
+	"testing"
+
+	"github.com/go-quicktest/qt"
+)
+
+func TestDependencyDepths(t *testing.T) {
+	deps := map[string][]string{
+		"a.repo":   nil,
+		"a.branch": {"a.repo"},
+		"a.prot":   {"a.repo", "a.branch"},
+		"a.other":  nil,
+	}
+
+	depths, err := dependencyDepths(deps)
+
+	qt.Assert(t, qt.IsNil(err))
+	qt.Assert(t, qt.DeepEquals(depths, map[string]int{
+		"a.repo":   0,
+		"a.branch": 1,
+		"a.prot":   2,
+		"a.other":  0,
+	}))
+}
+
+func TestDependencyDepthsCycle(t *testing.T) {
+	deps := map[string][]string{
+		"a.x": {"a.y"},
+		"a.y": {"a.x"},
+	}
+
+	_, err := dependencyDepths(deps)
+
+	qt.Assert(t, qt.ErrorMatches(err, `dependency cycle involving a\.x`))
+}
+
+func TestReferencedResource(t *testing.T) {
+	testCases := []struct {
+		ref    string
+		want   string
+		wantOk bool
+	}{
+		{ref: `github_repository.repos["foo"].name`, want: "github_repository.repos", wantOk: true},
+		{ref: "github_repository.repos", want: "github_repository.repos", wantOk: true},
+		{ref: "var.checks"},
+		{ref: "each.key"},
+		{ref: "module.github.repo_ids"},
+		{ref: "data.github_team.devs.id"},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.ref, func(t *testing.T) {
+			have, ok := referencedResource(tc.ref)
+
+			qt.Assert(t, qt.Equals(ok, tc.wantOk))
+			qt.Assert(t, qt.Equals(have, tc.want))
+		})
+	}
+}
+
+func TestProviderAliases(t *testing.T) {
+	data, err := os.ReadFile("testdata/import/33_import_src-plan_provider_alias.json")
+	qt.Assert(t, qt.IsNil(err))
+	var bundle ResourcesBundle
+	qt.Assert(t, qt.IsNil(json.Unmarshal(data, &bundle)))
+
+	aliases := bundle.Configuration.providerAliases()
+
+	qt.Assert(t, qt.DeepEquals(aliases, map[string]string{
+		"aws_s3_bucket.logs":               "aws.us_east_1",
+		"module.eu.aws_s3_bucket.this":     "aws.frankfurt",
+		"module.legacy.aws_s3_bucket.this": "module.legacy:aws.ireland",
+	}))
+}
@@ -0,0 +1,333 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// Special variables that can be used in a field reference of a resource definition,
+// in addition to the attributes of the "after" object of the resource.
+const (
+	// The index key of the resource instance: the for_each key or the count index.
+	// For example "foo" for `github_repository.repos["foo"]`.
+	varIndex = "$index"
+	// The list of the index keys of the module instances containing the resource,
+	// outermost first. For example ["a", "0"] for `module.x["a"].module.y[0].foo.bar`.
+	varModuleKeys = "$module_keys"
+)
+
+// A step of a field reference: either an object attribute or a list index.
+type pathStep struct {
+	key     string
+	index   int
+	isIndex bool
+}
+
+// parseFieldPath parses a field reference of a resource definition. The syntax is
+// a subset of JSONPath:
+//
+//	name
+//	tags.Name
+//	tags["Name"]
+//	settings[0].id
+//	$index
+//	$module_keys[0]
+func parseFieldPath(path string) ([]pathStep, error) {
+	var steps []pathStep
+	rest := path
+	for rest != "" {
+		switch {
+		case strings.HasPrefix(rest, `["`):
+			// Quoted key, allows any character.
+			end := strings.Index(rest, `"]`)
+			if end < 0 {
+				return nil, fmt.Errorf("field %q: missing closing \"]", path)
+			}
+			key, err := strconv.Unquote(rest[1 : end+1])
+			if err != nil {
+				return nil, fmt.Errorf("field %q: %s", path, err)
+			}
+			steps = append(steps, pathStep{key: key})
+			rest = rest[end+2:]
+		case strings.HasPrefix(rest, "["):
+			end := strings.Index(rest, "]")
+			if end < 0 {
+				return nil, fmt.Errorf("field %q: missing closing ]", path)
+			}
+			idx, err := strconv.Atoi(rest[1:end])
+			if err != nil || idx < 0 {
+				return nil, fmt.Errorf("field %q: invalid list index %q", path, rest[1:end])
+			}
+			steps = append(steps, pathStep{index: idx, isIndex: true})
+			rest = rest[end+1:]
+		default:
+			if len(steps) > 0 {
+				if !strings.HasPrefix(rest, ".") {
+					return nil, fmt.Errorf("field %q: unexpected %q", path, rest)
+				}
+				rest = rest[1:]
+			}
+			end := strings.IndexAny(rest, ".[")
+			if end < 0 {
+				end = len(rest)
+			}
+			if end == 0 {
+				return nil, fmt.Errorf("field %q: empty attribute name", path)
+			}
+			steps = append(steps, pathStep{key: rest[:end]})
+			rest = rest[end:]
+		}
+	}
+	if len(steps) == 0 {
+		return nil, fmt.Errorf("empty field")
+	}
+	return steps, nil
+}
+
+// lookupField returns the value of the field reference field (see parseFieldPath)
+// for resource, formatted as a string. Attributes are looked up in supplied (the
+// values supplied by the operator for resource, by field reference), then in after;
+// the special variables $index and $module_keys are derived from the address of
+// resource. A reference to another state (see stateRefPrefix) is looked up in states.
+//
+// Strings are returned as-is, numbers and booleans are formatted; any other type is
+// an error. An attribute that is unknown until apply or sensitive is an error too,
+// unless supplied.
+func lookupField(resource ResourceChange, after map[string]interface{},
+	supplied map[string]string, states lookupStates, field string,
+) (string, error) {
+	if isStateRef(field) {
+		val, err := states.lookup(field)
+		if err != nil {
+			return "", fmt.Errorf("resource %s: %s", resource.Address, err)
+		}
+		return val, nil
+	}
+	steps, err := parseFieldPath(field)
+	if err != nil {
+		return "", fmt.Errorf("error in resources definition %s: %s", resource.Type, err)
+	}
+
+	var val interface{} = after
+	switch steps[0].key {
+	case varIndex, varModuleKeys:
+		index, moduleKeys, err := addressKeys(resource.Address)
+		if err != nil {
+			return "", err
+		}
+		if steps[0].key == varIndex {
+			if index == nil {
+				return "", fmt.Errorf("resource %s: %s: the address has no index key",
+					resource.Address, varIndex)
+			}
+			val = *index
+		} else {
+			keys := make([]interface{}, 0, len(moduleKeys))
+			for _, k := range moduleKeys {
+				keys = append(keys, k)
+			}
+			val = keys
+		}
+		steps = steps[1:]
+	default:
+		if val, ok := supplied[field]; ok {
+			return val, nil
+		}
+		if masked(resource.Change.AfterUnknown, steps) {
+			return "", fmt.Errorf("resource %s: field '%s' is unknown until apply; "+
+				"supply its value with --attr-values", resource.Address, field)
+		}
+		if masked(resource.Change.AfterSensitive, steps) {
+			return "", fmt.Errorf("resource %s: field '%s' is sensitive; refusing to write "+
+				"it in the import script (to use it anyway, supply its value with "+
+				"--attr-values)", resource.Address, field)
+		}
+		if after == nil {
+			return "", fmt.Errorf("resource %s: the plan does not contain attribute values "+
+				"(use the output of 'terraform show -json')", resource.Address)
+		}
+	}
+
+	for _, step := range steps {
+		switch v := val.(type) {
+		case map[string]interface{}:
+			if step.isIndex {
+				return "", fmt.Errorf("resource_changes: after: %s: "+
+					"type is object; want: list", field)
+			}
+			elem, ok := v[step.key]
+			if !ok {
+				return "", fmt.Errorf(
+					"error in resources definition %s: field '%s' doesn't exist in plan",
+					resource.Type, field)
+			}
+			val = elem
+		case []interface{}:
+			if !step.isIndex {
+				return "", fmt.Errorf("resource_changes: after: %s: "+
+					"type is list; want: object", field)
+			}
+			if step.index >= len(v) {
+				return "", fmt.Errorf(
+					"error in resources definition %s: field '%s' doesn't exist in plan "+
+						"(list has %d elements)",
+					resource.Type, field, len(v))
+			}
+			val = v[step.index]
+		default:
+			return "", fmt.Errorf(
+				"error in resources definition %s: field '%s' doesn't exist in plan",
+				resource.Type, field)
+		}
+	}
+
+	switch v := val.(type) {
+	case string:
+		return v, nil
+	case json.Number:
+		return v.String(), nil
+	case float64:
+		return strconv.FormatFloat(v, 'f', -1, 64), nil
+	case bool:
+		return strconv.FormatBool(v), nil
+	case nil:
+		return "", fmt.Errorf("resource_changes: after: %s: value is null", field)
+	default:
+		return "", fmt.Errorf("resource_changes: after: %s: type is %T; "+
+			"want: string, number or bool", field, val)
+	}
+}
+
+// masked reports whether the attribute at steps, or one of its parents, is marked in
+// mask, an "after_unknown" or "after_sensitive" object of the plan. These objects
+// have the same structure as "after", with true for each marked value; a marked
+// object or list is marked as a whole.
+func masked(mask interface{}, steps []pathStep) bool {
+	for _, step := range steps {
+		switch m := mask.(type) {
+		case bool:
+			return m
+		case map[string]interface{}:
+			if step.isIndex {
+				return false
+			}
+			mask = m[step.key]
+		case []interface{}:
+			if !step.isIndex || step.index >= len(m) {
+				return false
+			}
+			mask = m[step.index]
+		default:
+			return false
+		}
+	}
+	marked, _ := mask.(bool)
+	return marked
+}
+
+// addressKeys parses the Terraform resource instance address addr and returns the
+// index key of the resource (nil if it has none) and the index keys of the module
+// instances containing it, outermost first (an empty string for a module without
+// index key). Numeric keys are returned in decimal notation.
+//
+// For example, for `module.x["a"].module.y[0].foo.bar["b"]` it returns "b" and
+// ["a", "0"].
+func addressKeys(addr string) (*string, []string, error) {
+	steps, err := splitAddress(addr)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	var moduleKeys []string
+	var lastKey *string
+	for i := 0; i < len(steps); i++ {
+		if steps[i].name == "module" && steps[i].key == nil && i+1 < len(steps) {
+			i++
+			mk := ""
+			if steps[i].key != nil {
+				mk = *steps[i].key
+			}
+			moduleKeys = append(moduleKeys, mk)
+			continue
+		}
+		lastKey = steps[i].key
+	}
+
+	return lastKey, moduleKeys, nil
+}
+
+// configAddress returns the configuration address of the resource instance address
+// addr, that is addr without the index keys.
+//
+// For example, for `module.x["a"].foo.bar["b"]` it returns `module.x.foo.bar`.
+func configAddress(addr string) (string, error) {
+	steps, err := splitAddress(addr)
+	if err != nil {
+		return "", err
+	}
+	names := make([]string, 0, len(steps))
+	for _, step := range steps {
+		names = append(names, step.name)
+	}
+	return strings.Join(names, "."), nil
+}
+
+// A step of a Terraform address: a name with an optional index key.
+type addrStep struct {
+	name string
+	key  *string
+}
+
+// splitAddress splits the Terraform address addr in its steps. For example
+// `module.x["a.b"].foo.bar[0]` is split in `module`, `x["a.b"]`, `foo`, `bar[0]`.
+// Numeric keys are returned in decimal notation, string keys are unquoted.
+func splitAddress(addr string) ([]addrStep, error) {
+	var steps []addrStep
+	rest := addr
+	for rest != "" {
+		// Name.
+		end := strings.IndexAny(rest, ".[")
+		if end < 0 {
+			end = len(rest)
+		}
+		step := addrStep{name: rest[:end]}
+		rest = rest[end:]
+
+		// Optional index key.
+		if strings.HasPrefix(rest, "[") {
+			var k string
+			if strings.HasPrefix(rest, `["`) {
+				// Find the closing quote, skipping escaped characters.
+				i := 2
+				for ; i < len(rest) && rest[i] != '"'; i++ {
+					if rest[i] == '\\' {
+						i++
+					}
+				}
+				if i+1 >= len(rest) || rest[i+1] != ']' {
+					return nil, fmt.Errorf("address %s: invalid index key", addr)
+				}
+				unq, err := strconv.Unquote(rest[1 : i+1])
+				if err != nil {
+					return nil, fmt.Errorf("address %s: invalid index key: %s", addr, err)
+				}
+				k = unq
+				rest = rest[i+2:]
+			} else {
+				i := strings.Index(rest, "]")
+				if i < 0 {
+					return nil, fmt.Errorf("address %s: invalid index key", addr)
+				}
+				k = rest[1:i]
+				rest = rest[i+1:]
+			}
+			step.key = &k
+		}
+		rest = strings.TrimPrefix(rest, ".")
+		steps = append(steps, step)
+	}
+
+	return steps, nil
+}
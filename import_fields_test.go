@@ -0,0 +1,210 @@
+package main
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/go-quicktest/qt"
+)
+
+func TestLookupFieldSuccess(t *testing.T) {
+	after := map[string]interface{}{
+		"name":     "foo",
+		"team_id":  json.Number("2817139"),
+		"archived": false,
+		"tags":     map[string]interface{}{"Name": "web", "a.b": "dotted"},
+		"settings": []interface{}{map[string]interface{}{"id": "s-1"}},
+	}
+	resource := ResourceChange{
+		Address: `module.x["a"].module.y[0].github_team_repository.all["foo.developers"]`,
+		Type:    "github_team_repository",
+	}
+
+	testCases := []struct {
+		field string
+		want  string
+	}{
+		{field: "name", want: "foo"},
+		{field: "team_id", want: "2817139"},
+		{field: "archived", want: "false"},
+		{field: "tags.Name", want: "web"},
+		{field: `tags["a.b"]`, want: "dotted"},
+		{field: "settings[0].id", want: "s-1"},
+		{field: "$index", want: "foo.developers"},
+		{field: "$module_keys[0]", want: "a"},
+		{field: "$module_keys[1]", want: "0"},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.field, func(t *testing.T) {
+			have, err := lookupField(resource, after, nil, nil, tc.field)
+
+			qt.Assert(t, qt.IsNil(err))
+			qt.Assert(t, qt.Equals(have, tc.want))
+		})
+	}
+}
+
+func TestLookupFieldFailure(t *testing.T) {
+	after := map[string]interface{}{
+		"name":     "foo",
+		"tags":     map[string]interface{}{"Name": "web"},
+		"settings": []interface{}{},
+		"parent":   nil,
+	}
+	resource := ResourceChange{Address: "github_repository.foo", Type: "github_repository"}
+
+	testCases := []struct {
+		field   string
+		wantErr string
+	}{
+		{
+			field:   "description",
+			wantErr: "error in resources definition github_repository: field 'description' doesn't exist in plan",
+		},
+		{
+			field:   "tags",
+			wantErr: "resource_changes: after: tags: type is map[string]interface {}; want: string, number or bool",
+		},
+		{
+			field:   "settings[0].id",
+			wantErr: "error in resources definition github_repository: field 'settings[0].id' doesn't exist in plan (list has 0 elements)",
+		},
+		{
+			field:   "parent",
+			wantErr: "resource_changes: after: parent: value is null",
+		},
+		{
+			field:   "$index",
+			wantErr: "resource github_repository.foo: $index: the address has no index key",
+		},
+		{
+			field:   "settings[x]",
+			wantErr: `error in resources definition github_repository: field "settings[x]": invalid list index "x"`,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.field, func(t *testing.T) {
+			_, err := lookupField(resource, after, nil, nil, tc.field)
+
+			qt.Assert(t, qt.IsNotNil(err))
+			qt.Assert(t, qt.Equals(err.Error(), tc.wantErr))
+		})
+	}
+}
+
+func TestLookupFieldUnknownAndSensitive(t *testing.T) {
+	after := map[string]interface{}{
+		"name":   "foo",
+		"secret": "hunter2",
+		"tags":   map[string]interface{}{},
+	}
+	resource := ResourceChange{Address: "aws_iam_role.foo", Type: "aws_iam_role"}
+	resource.Change.AfterUnknown = map[string]interface{}{
+		"arn":  true,
+		"tags": map[string]interface{}{"Name": true},
+	}
+	resource.Change.AfterSensitive = map[string]interface{}{"secret": true}
+	supplied := map[string]string{"arn": "arn:aws:iam::123:role/foo"}
+
+	testCases := []struct {
+		field   string
+		want    string
+		wantErr string
+	}{
+		{field: "name", want: "foo"},
+		{field: "arn", want: "arn:aws:iam::123:role/foo"},
+		{
+			field: "tags.Name",
+			wantErr: "resource aws_iam_role.foo: field 'tags.Name' is unknown until apply; " +
+				"supply its value with --attr-values",
+		},
+		{
+			field: "secret",
+			wantErr: "resource aws_iam_role.foo: field 'secret' is sensitive; refusing to " +
+				"write it in the import script (to use it anyway, supply its value with " +
+				"--attr-values)",
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.field, func(t *testing.T) {
+			have, err := lookupField(resource, after, supplied, nil, tc.field)
+
+			if tc.wantErr != "" {
+				qt.Assert(t, qt.IsNotNil(err))
+				qt.Assert(t, qt.Equals(err.Error(), tc.wantErr))
+				return
+			}
+			qt.Assert(t, qt.IsNil(err))
+			qt.Assert(t, qt.Equals(have, tc.want))
+		})
+	}
+}
+
+func TestMasked(t *testing.T) {
+	mask := map[string]interface{}{
+		"id":       true,
+		"name":     false,
+		"tags":     map[string]interface{}{"Name": true},
+		"settings": []interface{}{false, map[string]interface{}{"id": true}},
+	}
+
+	testCases := []struct {
+		field string
+		want  bool
+	}{
+		{field: "id", want: true},
+		{field: "id.nested", want: true},
+		{field: "name", want: false},
+		{field: "missing", want: false},
+		{field: "tags.Name", want: true},
+		{field: "tags.Other", want: false},
+		{field: "settings[0]", want: false},
+		{field: "settings[1].id", want: true},
+		{field: "settings[2].id", want: false},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.field, func(t *testing.T) {
+			steps, err := parseFieldPath(tc.field)
+			qt.Assert(t, qt.IsNil(err))
+
+			qt.Assert(t, qt.Equals(masked(mask, steps), tc.want))
+		})
+	}
+}
+
+func TestAddressKeys(t *testing.T) {
+	testCases := []struct {
+		addr           string
+		wantIndex      string
+		wantNoIndex    bool
+		wantModuleKeys []string
+	}{
+		{addr: "aws_instance.foo", wantNoIndex: true},
+		{addr: "aws_instance.foo[3]", wantIndex: "3"},
+		{addr: `data.aws_ami.foo["x"]`, wantIndex: "x"},
+		{
+			addr:           `module.a.module.b["k.1"].aws_instance.foo["say \"hi\""]`,
+			wantIndex:      `say "hi"`,
+			wantModuleKeys: []string{"", "k.1"},
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.addr, func(t *testing.T) {
+			index, moduleKeys, err := addressKeys(tc.addr)
+
+			qt.Assert(t, qt.IsNil(err))
+			if tc.wantNoIndex {
+				qt.Assert(t, qt.IsNil(index))
+			} else {
+				qt.Assert(t, qt.IsNotNil(index))
+				qt.Assert(t, qt.Equals(*index, tc.wantIndex))
+			}
+			qt.Assert(t, qt.DeepEquals(moduleKeys, tc.wantModuleKeys))
+		})
+	}
+}
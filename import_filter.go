@@ -0,0 +1,97 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/scylladb/go-set/strset"
+)
+
+// The resources to create removed by one of the filters of the import (see
+// filterResources).
+type filterResult struct {
+	option  string
+	removed []string
+}
+
+// filterResources returns the resources that pass the filters of opts, and the
+// addresses of the resources each filter removed. The filters are applied in order: opts.Types, then
+// opts.Include, then opts.Exclude.
+//
+// A type or a glob that matches none of resources is an error, since it is probably
+// a typo: with --exclude, it would silently import more than intended.
+func filterResources(resources []ResourceChange, opts ImportOptions,
+) ([]ResourceChange, []filterResult, error) {
+	var errs []error
+	types := strset.New(opts.Types...)
+	for _, resType := range opts.Types {
+		found := false
+		for _, res := range resources {
+			found = found || res.Type == resType
+		}
+		if !found {
+			errs = append(errs, fmt.Errorf("--type %s matches no resource to create "+
+				"in src-plan", resType))
+		}
+	}
+	compile := func(option string, patterns []string) []addrGlob {
+		globs := make([]addrGlob, 0, len(patterns))
+		for _, pattern := range patterns {
+			glob := newAddrGlob(pattern)
+			found := false
+			for _, res := range resources {
+				found = found || glob.match(res.Address)
+			}
+			if !found {
+				errs = append(errs, fmt.Errorf("%s %s matches no resource to create "+
+					"in src-plan", option, pattern))
+			}
+			globs = append(globs, glob)
+		}
+		return globs
+	}
+	include := compile("--include", opts.Include)
+	exclude := compile("--exclude", opts.Exclude)
+	if err := errors.Join(errs...); err != nil {
+		return nil, nil, err
+	}
+
+	matchAny := func(globs []addrGlob, addr string) bool {
+		for _, glob := range globs {
+			if glob.match(addr) {
+				return true
+			}
+		}
+		return false
+	}
+	var results []filterResult
+	apply := func(option string, keep func(res ResourceChange) bool) {
+		var kept []ResourceChange
+		result := filterResult{option: option}
+		for _, res := range resources {
+			if keep(res) {
+				kept = append(kept, res)
+			} else {
+				result.removed = append(result.removed, res.Address)
+			}
+		}
+		results = append(results, result)
+		resources = kept
+	}
+	if len(opts.Types) > 0 {
+		apply("--type", func(res ResourceChange) bool {
+			return types.Has(res.Type)
+		})
+	}
+	if len(include) > 0 {
+		apply("--include", func(res ResourceChange) bool {
+			return matchAny(include, res.Address)
+		})
+	}
+	if len(exclude) > 0 {
+		apply("--exclude", func(res ResourceChange) bool {
+			return !matchAny(exclude, res.Address)
+		})
+	}
+	return resources, results, nil
+}
@@ -0,0 +1,96 @@
+package main
+
+import (
+	"testing"
+
+	"github.com/go-quicktest/qt"
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestFilterResources(t *testing.T) {
+	resources := []ResourceChange{
+		{Address: "module.a.aws_vpc.main", Type: "aws_vpc"},
+		{Address: "module.a.aws_subnet.x", Type: "aws_subnet"},
+		{Address: "module.a.aws_subnet.y", Type: "aws_subnet"},
+		{Address: "module.b.aws_vpc.main", Type: "aws_vpc"},
+		{Address: "module.b.aws_subnet.x", Type: "aws_subnet"},
+		{Address: "aws_iam_role.deployer", Type: "aws_iam_role"},
+	}
+
+	testCases := []struct {
+		name        string
+		opts        ImportOptions
+		wantAddrs   []string
+		wantResults []filterResult
+		wantErr     string
+	}{
+		{
+			name: "no filters",
+			wantAddrs: []string{
+				"module.a.aws_vpc.main", "module.a.aws_subnet.x", "module.a.aws_subnet.y",
+				"module.b.aws_vpc.main", "module.b.aws_subnet.x", "aws_iam_role.deployer",
+			},
+		},
+		{
+			name:      "types",
+			opts:      ImportOptions{Types: []string{"aws_vpc", "aws_iam_role"}},
+			wantAddrs: []string{"module.a.aws_vpc.main", "module.b.aws_vpc.main", "aws_iam_role.deployer"},
+			wantResults: []filterResult{
+				{"--type", []string{"module.a.aws_subnet.x", "module.a.aws_subnet.y", "module.b.aws_subnet.x"}},
+			},
+		},
+		{
+			name: "all filters, in order",
+			opts: ImportOptions{
+				Types:   []string{"aws_subnet", "aws_vpc"},
+				Include: []string{"module.a.*", "module.b.aws_subnet.x"},
+				Exclude: []string{"*.y"},
+			},
+			wantAddrs: []string{"module.a.aws_vpc.main", "module.a.aws_subnet.x", "module.b.aws_subnet.x"},
+			wantResults: []filterResult{
+				{"--type", []string{"aws_iam_role.deployer"}},
+				{"--include", []string{"module.b.aws_vpc.main"}},
+				{"--exclude", []string{"module.a.aws_subnet.y"}},
+			},
+		},
+		{
+			name: "exclude everything",
+			opts: ImportOptions{Exclude: []string{"*"}},
+			wantResults: []filterResult{
+				{"--exclude", []string{
+					"module.a.aws_vpc.main", "module.a.aws_subnet.x", "module.a.aws_subnet.y",
+					"module.b.aws_vpc.main", "module.b.aws_subnet.x", "aws_iam_role.deployer",
+				}},
+			},
+		},
+		{
+			name: "typos",
+			opts: ImportOptions{
+				Types:   []string{"aws_vcp"},
+				Include: []string{"module.a.*"},
+				Exclude: []string{"module.c.*"},
+			},
+			wantErr: "--type aws_vcp matches no resource to create in src-plan\n" +
+				"--exclude module.c.* matches no resource to create in src-plan",
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			kept, results, err := filterResources(resources, tc.opts)
+
+			if tc.wantErr != "" {
+				qt.Assert(t, qt.IsNotNil(err))
+				qt.Assert(t, qt.Equals(err.Error(), tc.wantErr))
+				return
+			}
+			qt.Assert(t, qt.IsNil(err))
+			var addrs []string
+			for _, res := range kept {
+				addrs = append(addrs, res.Address)
+			}
+			qt.Assert(t, qt.CmpEquals(addrs, tc.wantAddrs))
+			qt.Assert(t, qt.CmpEquals(results, tc.wantResults, cmp.AllowUnexported(filterResult{})))
+		})
+	}
+}
@@ -0,0 +1,85 @@
+package main
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// A problem with the import ID of a resource, that would make terraform import the
+// wrong object or fail.
+type idProblem struct {
+	code    string // "invalid-id" or "duplicate-id"
+	addr    string
+	resType string
+	msg     string
+}
+
+// checkImportID returns the problems of the import ID id of the resource at addr:
+// an empty ID and, if the ID was built from variables (see Definitions.importID),
+// an empty component or a component other than the last containing the separator,
+// since the ID would then be split differently by the provider (":master" instead
+// of "repo:master"). The providers split on the first separators only, so the last
+// component can contain it, like the policy ARN of aws_iam_role_policy_attachment.
+func checkImportID(addr, resType, id string, def Definitions, components []string,
+) []idProblem {
+	invalid := func(format string, args ...interface{}) idProblem {
+		return idProblem{
+			code:    "invalid-id",
+			addr:    addr,
+			resType: resType,
+			msg: fmt.Sprintf("resource %s: import ID %q: %s", addr, id,
+				fmt.Sprintf(format, args...)),
+		}
+	}
+	if id == "" {
+		return []idProblem{invalid("empty")}
+	}
+	if len(components) < 2 {
+		return nil
+	}
+	var problems []idProblem
+	for i, comp := range components {
+		switch {
+		case comp == "":
+			problems = append(problems, invalid("variable %s is empty", def.Variables[i]))
+		case i < len(components)-1 && strings.Contains(comp, def.Separator):
+			problems = append(problems, invalid("variable %s (%q) contains the separator %q",
+				def.Variables[i], comp, def.Separator))
+		}
+	}
+	return problems
+}
+
+// duplicateImportIDs returns a problem for each import ID shared by resources of the
+// same type, since terraform would import the same object at different addresses.
+// Resources of different types can legitimately share an ID (for example a
+// repository and its settings).
+func duplicateImportIDs(imports []ImportElement) []idProblem {
+	type key struct{ resType, id string }
+	var order []key
+	addrs := map[key][]string{}
+	for _, elem := range imports {
+		k := key{elem.Type, elem.ID}
+		if _, ok := addrs[k]; !ok {
+			order = append(order, k)
+		}
+		addrs[k] = append(addrs[k], elem.Addr)
+	}
+
+	var problems []idProblem
+	for _, k := range order {
+		if len(addrs[k]) < 2 {
+			continue
+		}
+		sort.Strings(addrs[k])
+		problems = append(problems, idProblem{
+			code:    "duplicate-id",
+			addr:    addrs[k][0],
+			resType: k.resType,
+			msg: fmt.Sprintf("import ID %q of type %s is shared by resources %s",
+				k.id, k.resType, strings.Join(addrs[k], ", ")),
+		})
+	}
+	return problems
+}
@@ -0,0 +1,138 @@
+package main
+
+import (
+	"io"
+	"strings"
+	"testing"
+
+	"github.com/go-quicktest/qt"
+)
+
+func TestCheckImportID(t *testing.T) {
+	branch := Definitions{Variables: []string{"repository", "branch"}, Separator: ":"}
+
+	testCases := []struct {
+		name       string
+		id         string
+		def        Definitions
+		components []string
+		want       []string
+	}{
+		{
+			name:       "valid",
+			id:         "repo:master",
+			def:        branch,
+			components: []string{"repo", "master"},
+		},
+		{
+			name: "empty",
+			want: []string{`resource a.b: import ID "": empty`},
+		},
+		{
+			name:       "empty components",
+			id:         ":",
+			def:        branch,
+			components: []string{"", ""},
+			want: []string{
+				`resource a.b: import ID ":": variable repository is empty`,
+				`resource a.b: import ID ":": variable branch is empty`,
+			},
+		},
+		{
+			name:       "separator in a component",
+			id:         "a:b:master",
+			def:        branch,
+			components: []string{"a:b", "master"},
+			want: []string{
+				`resource a.b: import ID "a:b:master": variable repository ("a:b") ` +
+					`contains the separator ":"`,
+			},
+		},
+		{
+			name:       "separator in the last component",
+			id:         "repo:feat:x",
+			def:        branch,
+			components: []string{"repo", "feat:x"},
+		},
+		{
+			name:       "single variable",
+			id:         "a:b",
+			def:        Definitions{Variables: []string{"name"}},
+			components: []string{"a:b"},
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			var have []string
+			for _, p := range checkImportID("a.b", "a", tc.id, tc.def, tc.components) {
+				qt.Assert(t, qt.Equals(p.code, "invalid-id"))
+				have = append(have, p.msg)
+			}
+
+			qt.Assert(t, qt.DeepEquals(have, tc.want))
+		})
+	}
+}
+
+func TestDuplicateImportIDs(t *testing.T) {
+	imports := []ImportElement{
+		{Addr: "t.c", Type: "t", ID: "x"},
+		{Addr: "t.a", Type: "t", ID: "x"},
+		{Addr: "u.a", Type: "u", ID: "x"},
+		{Addr: "t.d", Type: "t", ID: "y"},
+	}
+
+	problems := duplicateImportIDs(imports)
+
+	qt.Assert(t, qt.Equals(len(problems), 1))
+	qt.Assert(t, qt.Equals(problems[0].addr, "t.a"))
+	qt.Assert(t, qt.Equals(problems[0].msg,
+		`import ID "x" of type t is shared by resources t.a, t.c`))
+}
+
+func TestImportBuiltinDefinitionsRealisticIDs(t *testing.T) {
+	configs, err := loadDefinitions([]string{"builtin:aws", "builtin:github"})
+	qt.Assert(t, qt.IsNil(err))
+	const plan = `{"resource_changes": [
+  {
+    "address": "aws_iam_role_policy_attachment.ro",
+    "type": "aws_iam_role_policy_attachment",
+    "change": {"actions": ["create"], "after": {
+      "role": "deployer", "policy_arn": "arn:aws:iam::aws:policy/ReadOnlyAccess"}}
+  },
+  {
+    "address": "aws_iam_user_policy_attachment.ro",
+    "type": "aws_iam_user_policy_attachment",
+    "change": {"actions": ["create"], "after": {
+      "user": "ci", "policy_arn": "arn:aws:iam::123456789012:policy/team/deploy"}}
+  },
+  {
+    "address": "aws_iam_group_policy_attachment.ro",
+    "type": "aws_iam_group_policy_attachment",
+    "change": {"actions": ["create"], "after": {
+      "group": "admins", "policy_arn": "arn:aws:iam::aws:policy/AdministratorAccess"}}
+  },
+  {
+    "address": "github_repository_file.codeowners",
+    "type": "github_repository_file",
+    "change": {"actions": ["create"], "after": {
+      "repository": "infra", "file": ".github/CODEOWNERS"}}
+  }
+]}`
+
+	imports, _, _, err := Import(strings.NewReader(plan), configs,
+		ImportOptions{Diagnostics: &diagnostics{out: io.Discard}})
+
+	qt.Assert(t, qt.IsNil(err))
+	var have []string
+	for _, elem := range imports {
+		have = append(have, elem.ID)
+	}
+	qt.Assert(t, qt.DeepEquals(have, []string{
+		"deployer/arn:aws:iam::aws:policy/ReadOnlyAccess",
+		"ci/arn:aws:iam::123456789012:policy/team/deploy",
+		"admins/arn:aws:iam::aws:policy/AdministratorAccess",
+		"infra/.github/CODEOWNERS",
+	}))
+}
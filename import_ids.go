@@ -0,0 +1,223 @@
+package main
+
+import (
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+// An inventory of import IDs, supplied by the operator for resources whose import
+// ID cannot be derived from the plan, like cloud-generated IDs. It maps resource
+// addresses, or address globs (see addrGlob), to import IDs.
+type idInventory struct {
+	exact map[string]string
+	globs []inventoryGlob
+}
+
+type inventoryGlob struct {
+	glob addrGlob
+	id   string
+}
+
+// loadIDInventory loads the ID inventory at path. The format is detected from the
+// extension (.csv or .json) or, failing that, from the contents.
+//
+// A CSV inventory has two columns, address and ID, with an optional header
+// "address,id"; lines starting with # are comments. A JSON inventory is an object
+// mapping addresses to IDs.
+func loadIDInventory(path string) (idInventory, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return idInventory{}, fmt.Errorf("opening the ids inventory: %v", err)
+	}
+
+	var entries [][2]string
+	isJSON := bytes.HasPrefix(bytes.TrimSpace(data), []byte("{"))
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".json":
+		isJSON = true
+	case ".csv":
+		isJSON = false
+	}
+	if isJSON {
+		entries, err = readJSONInventory(data)
+	} else {
+		entries, err = readCSVInventory(data)
+	}
+	if err != nil {
+		return idInventory{}, fmt.Errorf("parsing ids inventory %s: %s", path, err)
+	}
+
+	inv := idInventory{exact: map[string]string{}}
+	seen := map[string]bool{}
+	for _, entry := range entries {
+		addr, id := entry[0], entry[1]
+		if seen[addr] {
+			return idInventory{}, fmt.Errorf("ids inventory %s: duplicate address %s",
+				path, addr)
+		}
+		seen[addr] = true
+		if id == "" {
+			return idInventory{}, fmt.Errorf("ids inventory %s: %s: empty ID", path, addr)
+		}
+		if isAddrGlob(addr) {
+			inv.globs = append(inv.globs, inventoryGlob{glob: newAddrGlob(addr), id: id})
+		} else {
+			inv.exact[addr] = id
+		}
+	}
+	return inv, nil
+}
+
+func readCSVInventory(data []byte) ([][2]string, error) {
+	rd := csv.NewReader(bytes.NewReader(data))
+	rd.Comment = '#'
+	rd.FieldsPerRecord = 2
+	rd.TrimLeadingSpace = true
+	var entries [][2]string
+	for first := true; ; first = false {
+		record, err := rd.Read()
+		if err == io.EOF {
+			return entries, nil
+		}
+		if err != nil {
+			return nil, err
+		}
+		if first && strings.EqualFold(record[0], "address") &&
+			strings.EqualFold(record[1], "id") {
+			continue
+		}
+		entries = append(entries, [2]string{record[0], record[1]})
+	}
+}
+
+func readJSONInventory(data []byte) ([][2]string, error) {
+	var ids map[string]string
+	if err := json.Unmarshal(data, &ids); err != nil {
+		return nil, err
+	}
+	addrs := make([]string, 0, len(ids))
+	for addr := range ids {
+		addrs = append(addrs, addr)
+	}
+	sort.Strings(addrs)
+	entries := make([][2]string, 0, len(ids))
+	for _, addr := range addrs {
+		entries = append(entries, [2]string{addr, ids[addr]})
+	}
+	return entries, nil
+}
+
+// lookup returns the import ID for the resource address addr. An exact address wins
+// over globs; among globs, the most specific one wins (see addrGlob.specificity). It
+// is an error if the most specific matching globs are tied. It returns false if no
+// entry matches.
+func (inv idInventory) lookup(addr string) (string, bool, error) {
+	if id, ok := inv.exact[addr]; ok {
+		return id, true, nil
+	}
+	// tied is a glob as specific as best, if any: a more specific glob found later
+	// still wins.
+	var best, tied *inventoryGlob
+	for i, ig := range inv.globs {
+		if !ig.glob.match(addr) {
+			continue
+		}
+		switch {
+		case best == nil || ig.glob.specificity() > best.glob.specificity():
+			best, tied = &inv.globs[i], nil
+		case ig.glob.specificity() == best.glob.specificity() && tied == nil:
+			tied = &inv.globs[i]
+		}
+	}
+	if best == nil {
+		return "", false, nil
+	}
+	if tied != nil {
+		return "", false, fmt.Errorf("ids inventory: resource %s: "+
+			"ambiguous globs %s and %s", addr, best.glob.pattern, tied.glob.pattern)
+	}
+	return best.id, true, nil
+}
+
+// check verifies that each entry of the inventory matches at least one of addrs,
+// to catch typos, and that each glob matches at most one of addrs, since different
+// resources cannot have the same import ID.
+func (inv idInventory) check(addrs []string) error {
+	var errs []error
+	exact := make([]string, 0, len(inv.exact))
+	for addr := range inv.exact {
+		exact = append(exact, addr)
+	}
+	for _, addr := range sorted(exact) {
+		found := false
+		for _, a := range addrs {
+			found = found || a == addr
+		}
+		if !found {
+			errs = append(errs, fmt.Errorf("ids inventory: resource %s "+
+				"is not a resource to create in src-plan", addr))
+		}
+	}
+	for _, ig := range inv.globs {
+		var matches []string
+		for _, a := range addrs {
+			if ig.glob.match(a) {
+				matches = append(matches, a)
+			}
+		}
+		switch {
+		case len(matches) == 0:
+			errs = append(errs, fmt.Errorf("ids inventory: glob %s "+
+				"matches no resource to create in src-plan", ig.glob.pattern))
+		case len(matches) > 1:
+			errs = append(errs, fmt.Errorf("ids inventory: glob %s "+
+				"matches %d resources (%s); they cannot have the same import ID",
+				ig.glob.pattern, len(matches), strings.Join(matches, ", ")))
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// An address glob: `*` matches any sequence of characters (dots and brackets
+// included), everything else matches literally. For example
+// `module.*.aws_vpc.main` matches `module.prod.aws_vpc.main` and
+// `module.net["eu"].aws_vpc.main`.
+type addrGlob struct {
+	pattern string
+	re      *regexp.Regexp
+}
+
+// isAddrGlob reports whether s is an address glob instead of an address.
+func isAddrGlob(s string) bool {
+	return strings.Contains(s, "*")
+}
+
+func newAddrGlob(pattern string) addrGlob {
+	parts := strings.Split(pattern, "*")
+	for i, part := range parts {
+		parts[i] = regexp.QuoteMeta(part)
+	}
+	return addrGlob{
+		pattern: pattern,
+		re:      regexp.MustCompile("^" + strings.Join(parts, ".*") + "$"),
+	}
+}
+
+func (g addrGlob) match(addr string) bool {
+	return g.re.MatchString(addr)
+}
+
+// specificity returns the number of literal characters of the glob: when more globs
+// match the same address, the one with more literal characters is more specific.
+func (g addrGlob) specificity() int {
+	return len(g.pattern) - strings.Count(g.pattern, "*")
+}
@@ -0,0 +1,148 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/go-quicktest/qt"
+)
+
+func TestAddrGlob(t *testing.T) {
+	testCases := []struct {
+		pattern string
+		addr    string
+		want    bool
+	}{
+		{pattern: "aws_vpc.*", addr: "aws_vpc.main", want: true},
+		{pattern: "module.*.aws_vpc.main", addr: `module.net["eu"].aws_vpc.main`, want: true},
+		{pattern: `aws_s3_bucket.b["*"]`, addr: `aws_s3_bucket.b["logs"]`, want: true},
+		{pattern: `aws_s3_bucket.b["*"]`, addr: "aws_s3_bucket.b[0]", want: false},
+		{pattern: "aws_vpc.*", addr: "module.x.aws_vpc.main", want: false},
+		{pattern: "aws_vpc.m.n", addr: "aws_vpc.main", want: false},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.pattern+" "+tc.addr, func(t *testing.T) {
+			qt.Assert(t, qt.Equals(newAddrGlob(tc.pattern).match(tc.addr), tc.want))
+		})
+	}
+}
+
+func TestIDInventoryLookup(t *testing.T) {
+	inv := idInventory{
+		exact: map[string]string{"aws_vpc.main": "vpc-exact"},
+		globs: []inventoryGlob{
+			{glob: newAddrGlob("aws_vpc.*"), id: "vpc-glob"},
+			{glob: newAddrGlob("module.prod.aws_vpc.*"), id: "vpc-prod"},
+			{glob: newAddrGlob("*.aws_subnet.a"), id: "subnet-a"},
+			{glob: newAddrGlob("module.x.aws_*"), id: "module-x"},
+			// Tied, but both less specific than the last one.
+			{glob: newAddrGlob("*.aws_eip.*"), id: "eip-any"},
+			{glob: newAddrGlob("module.z.*"), id: "module-z"},
+			{glob: newAddrGlob("module.z.aws_eip.*"), id: "module-z-eip"},
+		},
+	}
+
+	testCases := []struct {
+		addr      string
+		wantID    string
+		wantFound bool
+		wantErr   string
+	}{
+		{addr: "aws_vpc.main", wantID: "vpc-exact", wantFound: true},
+		{addr: "aws_vpc.other", wantID: "vpc-glob", wantFound: true},
+		{addr: "module.prod.aws_vpc.main", wantID: "vpc-prod", wantFound: true},
+		{addr: "aws_subnet.b", wantFound: false},
+		{addr: "module.y.aws_subnet.a", wantID: "subnet-a", wantFound: true},
+		{addr: "module.x.aws_subnet.b", wantID: "module-x", wantFound: true},
+		{
+			addr: "module.x.aws_subnet.a",
+			wantErr: "ids inventory: resource module.x.aws_subnet.a: " +
+				"ambiguous globs *.aws_subnet.a and module.x.aws_*",
+		},
+		{addr: "module.z.aws_eip.a", wantID: "module-z-eip", wantFound: true},
+		{addr: "module.z.aws_eip_association.a", wantID: "module-z", wantFound: true},
+		{addr: "module.y.aws_eip.a", wantID: "eip-any", wantFound: true},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.addr, func(t *testing.T) {
+			id, found, err := inv.lookup(tc.addr)
+
+			if tc.wantErr != "" {
+				qt.Assert(t, qt.IsNotNil(err))
+				qt.Assert(t, qt.Equals(err.Error(), tc.wantErr))
+				return
+			}
+			qt.Assert(t, qt.IsNil(err))
+			qt.Assert(t, qt.Equals(found, tc.wantFound))
+			qt.Assert(t, qt.Equals(id, tc.wantID))
+		})
+	}
+}
+
+func TestLoadIDInventory(t *testing.T) {
+	testCases := []struct {
+		name     string
+		filename string
+		contents string
+		wantErr  string
+	}{
+		{
+			name:     "CSV without header",
+			filename: "ids.csv",
+			contents: "aws_vpc.main,vpc-1\n\"aws_s3_bucket.b[\"\"logs\"\"]\",logs-bucket\n",
+		},
+		{
+			name:     "JSON",
+			filename: "ids.json",
+			contents: `{"aws_vpc.main": "vpc-1", "aws_s3_bucket.b[\"logs\"]": "logs-bucket"}`,
+		},
+		{
+			name:     "JSON detected from contents",
+			filename: "ids.txt",
+			contents: `{"aws_vpc.main": "vpc-1", "aws_s3_bucket.b[\"logs\"]": "logs-bucket"}`,
+		},
+		{
+			name:     "duplicate address",
+			filename: "ids.csv",
+			contents: "aws_vpc.main,vpc-1\naws_vpc.main,vpc-2\n",
+			wantErr:  "ids inventory IDS.csv: duplicate address aws_vpc.main",
+		},
+		{
+			name:     "empty ID",
+			filename: "ids.csv",
+			contents: "aws_vpc.main,\n",
+			wantErr:  "ids inventory IDS.csv: aws_vpc.main: empty ID",
+		},
+		{
+			name:     "wrong number of columns",
+			filename: "ids.csv",
+			contents: "aws_vpc.main,vpc-1,extra\n",
+			wantErr:  "parsing ids inventory IDS.csv: record on line 1: wrong number of fields",
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), tc.filename)
+			qt.Assert(t, qt.IsNil(os.WriteFile(path, []byte(tc.contents), 0o644)))
+
+			inv, err := loadIDInventory(path)
+
+			if tc.wantErr != "" {
+				qt.Assert(t, qt.IsNotNil(err))
+				qt.Assert(t, qt.Equals(err.Error(), strings.ReplaceAll(tc.wantErr,
+					"IDS"+filepath.Ext(path), path)))
+				return
+			}
+			qt.Assert(t, qt.IsNil(err))
+			qt.Assert(t, qt.DeepEquals(inv.exact, map[string]string{
+				"aws_vpc.main":            "vpc-1",
+				`aws_s3_bucket.b["logs"]`: "logs-bucket",
+			}))
+		})
+	}
+}
@@ -0,0 +1,163 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+// The prefix of a field reference to an attribute of a resource in another state:
+//
+//	state:PATH#ADDRESS.ATTRIBUTE
+//
+// for example `state:../github/local.tfstate#github_team.teams["devs"].id`. The
+// state at PATH must be passed with --lookup-state.
+const stateRefPrefix = "state:"
+
+// isStateRef reports whether the field reference field refers to another state.
+func isStateRef(field string) bool {
+	return strings.HasPrefix(field, stateRefPrefix)
+}
+
+// parseStateRef splits the state reference ref in the path of the state and the
+// rest, the address of the resource instance followed by the attribute.
+func parseStateRef(ref string) (string, string, error) {
+	path, rest, ok := strings.Cut(strings.TrimPrefix(ref, stateRefPrefix), "#")
+	if !ok || path == "" || rest == "" {
+		return "", "", fmt.Errorf("field %q: want: %sPATH#ADDRESS.ATTRIBUTE", ref,
+			stateRefPrefix)
+	}
+	return filepath.Clean(path), rest, nil
+}
+
+// Terraform states to look up values from, by path (cleaned), then by resource
+// instance address. The values are the instance objects of the state.
+type lookupStates map[string]map[string]map[string]interface{}
+
+// loadLookupStates loads the states at paths (see lookupStates).
+func loadLookupStates(paths []string) (lookupStates, error) {
+	states := lookupStates{}
+	for _, path := range paths {
+		state, err := readState(path)
+		if err != nil {
+			return nil, fmt.Errorf("lookup state: %s", err)
+		}
+		resources, err := stateResources(state)
+		if err != nil {
+			return nil, fmt.Errorf("lookup state %s: %s", path, err)
+		}
+		instances := map[string]map[string]interface{}{}
+		for _, res := range resources {
+			for _, inst := range resourceInstances(res) {
+				if obj, ok := inst.(map[string]interface{}); ok {
+					instances[resourceKey(res)+instanceKey(inst)] = obj
+				}
+			}
+		}
+		states[filepath.Clean(path)] = instances
+	}
+	return states, nil
+}
+
+// lookup returns the value of the state reference ref (see stateRefPrefix), formatted
+// as a string like lookupField does. The resource address is the longest prefix of
+// the rest of ref that is an instance address in the state.
+func (states lookupStates) lookup(ref string) (string, error) {
+	path, rest, err := parseStateRef(ref)
+	if err != nil {
+		return "", err
+	}
+	instances, ok := states[path]
+	if !ok {
+		return "", fmt.Errorf("field '%s': state %s not passed with --lookup-state",
+			ref, path)
+	}
+
+	var addr string
+	for candidate := range instances {
+		if strings.HasPrefix(rest, candidate+".") && len(candidate) > len(addr) {
+			addr = candidate
+		}
+	}
+	if addr == "" {
+		return "", fmt.Errorf("field '%s': no resource instance in state %s", ref, path)
+	}
+	inst := instances[addr]
+	steps, err := parseFieldPath(rest[len(addr)+1:])
+	if err != nil {
+		return "", fmt.Errorf("field '%s': %s", ref, err)
+	}
+	if stateSensitive(inst["sensitive_attributes"], steps) {
+		return "", fmt.Errorf("field '%s' is sensitive; refusing to write it in the "+
+			"import script", ref)
+	}
+
+	var val interface{} = inst["attributes"]
+	for _, step := range steps {
+		switch v := val.(type) {
+		case map[string]interface{}:
+			elem, ok := v[step.key]
+			if step.isIndex || !ok {
+				return "", fmt.Errorf("field '%s' doesn't exist in state %s", ref, path)
+			}
+			val = elem
+		case []interface{}:
+			if !step.isIndex || step.index >= len(v) {
+				return "", fmt.Errorf("field '%s' doesn't exist in state %s", ref, path)
+			}
+			val = v[step.index]
+		default:
+			return "", fmt.Errorf("field '%s' doesn't exist in state %s", ref, path)
+		}
+	}
+
+	switch v := val.(type) {
+	case string:
+		return v, nil
+	case json.Number:
+		return v.String(), nil
+	case bool:
+		return strconv.FormatBool(v), nil
+	default:
+		return "", fmt.Errorf("field '%s': type is %s; want: string, number or bool",
+			ref, typeName(val))
+	}
+}
+
+// stateSensitive reports whether the attribute at steps, or one of its parents, is
+// in sensitive, the "sensitive_attributes" of a resource instance of the state: a
+// list of paths, each a list of steps like {"type": "get_attr", "value": "password"}
+// or {"type": "index", "value": {"value": 0, "type": "number"}}.
+func stateSensitive(sensitive interface{}, steps []pathStep) bool {
+	paths, _ := sensitive.([]interface{})
+	for _, p := range paths {
+		path, _ := p.([]interface{})
+		if len(path) == 0 || len(path) > len(steps) {
+			continue
+		}
+		prefix := true
+		for i, elem := range path {
+			step, _ := elem.(map[string]interface{})
+			switch step["type"] {
+			case "get_attr":
+				prefix = prefix && !steps[i].isIndex && step["value"] == steps[i].key
+			case "index":
+				index, _ := step["value"].(map[string]interface{})
+				want := fmt.Sprint(index["value"])
+				if steps[i].isIndex {
+					prefix = prefix && want == strconv.Itoa(steps[i].index)
+				} else {
+					prefix = prefix && want == steps[i].key
+				}
+			default:
+				prefix = false
+			}
+		}
+		if prefix {
+			return true
+		}
+	}
+	return false
+}
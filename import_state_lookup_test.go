@@ -0,0 +1,85 @@
+package main
+
+import (
+	"testing"
+
+	"github.com/go-quicktest/qt"
+)
+
+func TestLookupStates(t *testing.T) {
+	const state = `{
+  "version": 4, "serial": 1, "lineage": "L",
+  "resources": [
+    {"mode": "managed", "type": "github_team", "name": "t", "instances": [
+      {"index_key": "devs", "attributes": {"id": "123", "slug": "devs", "members": ["a", "b"]}},
+      {"index_key": "ops.x", "attributes": {"id": "456", "ldap_dn": null}}
+    ]},
+    {"module": "module.m", "mode": "managed", "type": "github_team", "name": "t",
+     "instances": [{"attributes": {"id": 789, "secret": "s"},
+       "sensitive_attributes": [[{"type": "get_attr", "value": "secret"}]]}]},
+    {"mode": "data", "type": "github_user", "name": "u", "instances": [
+      {"attributes": {"id": "1", "site_admin": false}}
+    ]}
+  ]
+}`
+	var decoded map[string]interface{}
+	qt.Assert(t, qt.IsNil(unmarshalUseNumber([]byte(state), &decoded)))
+	resources, err := stateResources(decoded)
+	qt.Assert(t, qt.IsNil(err))
+	instances := map[string]map[string]interface{}{}
+	for _, res := range resources {
+		for _, inst := range resourceInstances(res) {
+			instances[resourceKey(res)+instanceKey(inst)] = inst.(map[string]interface{})
+		}
+	}
+	states := lookupStates{"../a/local.tfstate": instances}
+
+	testCases := []struct {
+		ref     string
+		want    string
+		wantErr string
+	}{
+		{ref: `state:../a/local.tfstate#github_team.t["devs"].id`, want: "123"},
+		{ref: `state:../a/./local.tfstate#github_team.t["devs"].members[1]`, want: "b"},
+		{ref: `state:../a/local.tfstate#github_team.t["ops.x"].id`, want: "456"},
+		{ref: `state:../a/local.tfstate#module.m.github_team.t.id`, want: "789"},
+		{ref: `state:../a/local.tfstate#data.github_user.u.site_admin`, want: "false"},
+		{
+			ref:     `state:../b/local.tfstate#github_team.t["devs"].id`,
+			wantErr: `field 'state:../b/local.tfstate#.*': state ../b/local.tfstate not passed with --lookup-state`,
+		},
+		{
+			ref:     `state:../a/local.tfstate#github_team.t["qa"].id`,
+			wantErr: `field '.*': no resource instance in state ../a/local.tfstate`,
+		},
+		{
+			ref:     `state:../a/local.tfstate#github_team.t["devs"].name`,
+			wantErr: `field '.*' doesn't exist in state ../a/local.tfstate`,
+		},
+		{
+			ref:     `state:../a/local.tfstate#github_team.t["ops.x"].ldap_dn`,
+			wantErr: `field '.*': type is null; want: string, number or bool`,
+		},
+		{
+			ref:     `state:../a/local.tfstate#module.m.github_team.t.secret`,
+			wantErr: `field '.*' is sensitive; refusing to write it in the import script`,
+		},
+		{
+			ref:     `state:../a/local.tfstate`,
+			wantErr: `field "state:../a/local.tfstate": want: state:PATH#ADDRESS.ATTRIBUTE`,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.ref, func(t *testing.T) {
+			have, err := states.lookup(tc.ref)
+
+			if tc.wantErr != "" {
+				qt.Assert(t, qt.ErrorMatches(err, tc.wantErr))
+				return
+			}
+			qt.Assert(t, qt.IsNil(err))
+			qt.Assert(t, qt.Equals(have, tc.want))
+		})
+	}
+}
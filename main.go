@@ -28,12 +28,14 @@ func Main() int {
 }
 
 type Args struct {
-	Rename     *RenameCmd     `arg:"subcommand:rename" help:"rename resources in the same root environment"`
-	MoveAfter  *MoveAfterCmd  `arg:"subcommand:move-after" help:"move resources from one root environment to AFTER another"`
-	MoveBefore *MoveBeforeCmd `arg:"subcommand:move-before" help:"move resources from one root environment to BEFORE another"`
-	Import     *ImportCmd     `arg:"subcommand:import" help:"import resources generated out-of-band of Terraform"`
-	Remove     *RemoveCmd     `arg:"subcommand:remove" help:"remove resources"`
-	Version    *struct{}      `arg:"subcommand:version" help:"show version"`
+	Rename      *RenameCmd      `arg:"subcommand:rename" help:"rename resources in the same root environment"`
+	MoveAfter   *MoveAfterCmd   `arg:"subcommand:move-after" help:"move resources from one root environment to AFTER another"`
+	MoveBefore  *MoveBeforeCmd  `arg:"subcommand:move-before" help:"move resources from one root environment to BEFORE another"`
+	Import      *ImportCmd      `arg:"subcommand:import" help:"import resources generated out-of-band of Terraform"`
+	ImportDefs  *ImportDefsCmd  `arg:"subcommand:import-defs" help:"manage resource definitions for import"`
+	Remove      *RemoveCmd      `arg:"subcommand:remove" help:"remove resources"`
+	MergeStates *MergeStatesCmd `arg:"subcommand:merge-states" help:"merge copies of a local state, as used by the parallel import script"`
+	Version     *struct{}       `arg:"subcommand:version" help:"show version"`
 }
 
 func (Args) Description() string {
@@ -45,34 +47,84 @@ type UpDown struct {
 	Down string `arg:"required" help:"path of the down script to generate (NNN_TITLE.down.sh)"`
 }
 
+// Embedded by the commands that read a plan.
+type TerraformBin struct {
+	TerraformBin string `arg:"--terraform-bin" help:"terraform executable, used to convert a binary plan (terraform plan -out) to JSON" default:"terraform"`
+}
+
 type RenameCmd struct {
 	UpDown
-	PlanPath       string `arg:"--plan,required" help:"path to the terraform plan"`
+	TerraformBin
+	PlanPath       string `arg:"--plan,required" help:"path to the terraform plan (- for stdin)"`
 	LocalStatePath string `arg:"--local-state" help:"path to the local state to modify (both src and dst)" default:"local.tfstate"`
 	FuzzyMatch     bool   `arg:"--fuzzy-match" help:"enable q-gram distance fuzzy matching. WARNING: You must validate by hand the output!"`
 }
 
 type MoveAfterCmd struct {
-	Script string `arg:"required" help:"the migration scripts; will generate SCRIPT_up.sh and SCRIPT_down.sh"`
-	Before string `arg:"required" help:"the before root directory; will look for BEFORE.tfplan and BEFORE.tfstate"`
-	After  string `arg:"required" help:"the after root directory; will look for AFTER.tfplan and AFTER.tfstate"`
+	TerraformBin
+	Script     string `arg:"required" help:"the migration scripts; will generate SCRIPT_up.sh and SCRIPT_down.sh"`
+	Before     string `arg:"required" help:"the before root directory; will look for BEFORE.tfplan and BEFORE.tfstate"`
+	After      string `arg:"required" help:"the after root directory; will look for AFTER.tfplan and AFTER.tfstate"`
+	BeforePlan string `arg:"--before-plan" help:"path to the BEFORE plan, instead of BEFORE.tfplan (- for stdin)"`
+	AfterPlan  string `arg:"--after-plan" help:"path to the AFTER plan, instead of AFTER.tfplan (- for stdin)"`
 }
 
 type MoveBeforeCmd struct {
-	Script string `arg:"required" help:"the migration scripts; will generate SCRIPT_up.sh and SCRIPT_down.sh"`
-	Before string `arg:"required" help:"the before root directory; will look for BEFORE.tfplan and BEFORE.tfstate"`
-	After  string `arg:"required" help:"the after root directory; will look for AFTER.tfstate"`
+	TerraformBin
+	Script     string `arg:"required" help:"the migration scripts; will generate SCRIPT_up.sh and SCRIPT_down.sh"`
+	Before     string `arg:"required" help:"the before root directory; will look for BEFORE.tfplan and BEFORE.tfstate"`
+	After      string `arg:"required" help:"the after root directory; will look for AFTER.tfstate"`
+	BeforePlan string `arg:"--before-plan" help:"path to the BEFORE plan, instead of BEFORE.tfplan (- for stdin)"`
 }
 
 type ImportCmd struct {
-	UpDown
-	ResourceDefs string `arg:"--res-defs,required" help:"path to resource definitions"`
-	SrcPlanPath  string `arg:"--src-plan,required" help:"path to the SRC terraform plan in JSON format"`
+	// Like UpDown, but not required with --dry-run.
+	Up   string `help:"path of the up script to generate (NNN_TITLE.up.sh); required unless --dry-run"`
+	Down string `help:"path of the down script to generate (NNN_TITLE.down.sh); required unless --dry-run"`
+	TerraformBin
+	ResourceDefs []string `arg:"--res-defs,required,separate" help:"path to resource definitions, or builtin:NAME for the built-in definitions of provider NAME; can be repeated, later definitions override earlier ones"`
+	SrcPlanPath  string   `arg:"--src-plan,required" help:"path to the SRC terraform plan, in JSON or text format (- for stdin)"`
+	OrderByDeps  bool     `arg:"--order-by-deps" help:"within the same priority, import parents before their dependents, according to the configuration in the plan"`
+	IDs          string   `arg:"--ids" help:"path to a CSV or JSON inventory mapping resource addresses (or globs) to import IDs; takes precedence over the resource definitions"`
+	AttrValues   string   `arg:"--attr-values" help:"path to a JSON file of attribute values by resource address, for attributes unknown until apply or sensitive in the plan"`
+	LookupStates []string `arg:"--lookup-state,separate" help:"path to a local state of another root, for the state:PATH#ADDRESS.ATTRIBUTE field references of the resource definitions; can be repeated" placeholder:"PATH"`
+	Types        []string `arg:"--type,separate" help:"import only resources of this type; can be repeated"`
+	Include      []string `arg:"--include,separate" help:"import only resources whose address matches this glob (* matches any sequence of characters); can be repeated"`
+	Exclude      []string `arg:"--exclude,separate" help:"do not import resources whose address matches this glob; can be repeated"`
+	Strict       bool     `arg:"--strict" help:"fail if a resource to import has no resources definition, instead of skipping it with a warning"`
+	AllowDupIDs  bool     `arg:"--allow-duplicate-ids" help:"warn about duplicate import IDs, empty ID components and ID components containing the separator, instead of failing"`
+	Diagnostics  string   `arg:"--diagnostics" help:"format of the warnings and of the summary, written to stderr: text or json" default:"text"`
+	DryRun       bool     `arg:"--dry-run" help:"do not generate the scripts; print instead the resources to import, with their ID and resources definition, and the skipped resources"`
+	DryRunFormat string   `arg:"--dry-run-format" help:"format of the --dry-run report: text or json" default:"text"`
+	TFArgs       []string `arg:"--tf-arg,separate" help:"option to pass to each terraform import command, like -var-file=prod.tfvars; can be repeated"`
+	Parallel     int      `arg:"--parallel" help:"generate an up script that imports each priority level with up to N parallel workers, each against a copy of the local state" placeholder:"N"`
+	LocalState   string   `arg:"--local-state" help:"path to the local state to import into, with --parallel" default:"local.tfstate"`
+	ProviderFlag bool     `arg:"--import-provider-flag" help:"add -provider=NAME.ALIAS to the import of resources of aliased providers, for Terraform before 0.13"`
+}
+
+type ImportDefsCmd struct {
+	Init     *ImportDefsInitCmd     `arg:"subcommand:init" help:"generate a skeleton of resource definitions from a plan, to be trimmed by hand"`
+	Validate *ImportDefsValidateCmd `arg:"subcommand:validate" help:"strictly validate resource definitions files"`
+}
+
+type ImportDefsInitCmd struct {
+	TerraformBin
+	SrcPlanPath string `arg:"--src-plan,required" help:"path to the SRC terraform plan, in JSON or text format (- for stdin)"`
+}
+
+type ImportDefsValidateCmd struct {
+	ResourceDefs []string `arg:"positional,required" placeholder:"DEFS" help:"paths of the resource definitions files to validate (or builtin:NAME)"`
+}
+
+type MergeStatesCmd struct {
+	State   string   `arg:"--state,required" help:"path to the local state to merge into"`
+	Sources []string `arg:"positional,required" placeholder:"SRC" help:"paths of the copies of the state to merge"`
 }
 
 type RemoveCmd struct {
+	TerraformBin
 	Up   string `arg:"required" help:"path of the up script to generate (NNN_TITLE.up.sh)"`
-	Plan string `arg:"required" help:"path to to the output of 'terraform plan -no-color'"`
+	Plan string `arg:"required" help:"path to to the output of 'terraform plan -no-color' (- for stdin)"`
 }
 
 func run() error {
@@ -87,19 +139,54 @@ func run() error {
 	case args.Rename != nil:
 		cmd := args.Rename
 		return doRename(cmd.Up, cmd.Down, cmd.PlanPath, cmd.LocalStatePath,
-			cmd.FuzzyMatch)
+			cmd.FuzzyMatch, cmd.TerraformBin.TerraformBin)
 	case args.MoveAfter != nil:
 		cmd := args.MoveAfter
-		return doMoveAfter(cmd.Script, cmd.Before, cmd.After)
+		return doMoveAfter(cmd.Script, cmd.Before, cmd.After, cmd.BeforePlan, cmd.AfterPlan,
+			cmd.TerraformBin.TerraformBin)
 	case args.MoveBefore != nil:
 		cmd := args.MoveBefore
-		return doMoveBefore(cmd.Script, cmd.Before, cmd.After)
+		return doMoveBefore(cmd.Script, cmd.Before, cmd.After, cmd.BeforePlan,
+			cmd.TerraformBin.TerraformBin)
 	case args.Import != nil:
 		cmd := args.Import
-		return doImport(cmd.Up, cmd.Down, cmd.SrcPlanPath, cmd.ResourceDefs)
+		if !cmd.DryRun && (cmd.Up == "" || cmd.Down == "") {
+			return fmt.Errorf("--up and --down are required (unless --dry-run)")
+		}
+		diags, err := newDiagnostics(os.Stderr, cmd.Diagnostics)
+		if err != nil {
+			return err
+		}
+		return doImport(cmd.Up, cmd.Down, cmd.SrcPlanPath, cmd.ResourceDefs,
+			cmd.AttrValues, cmd.IDs, cmd.LookupStates, cmd.TerraformBin.TerraformBin,
+			cmd.DryRun, cmd.DryRunFormat,
+			ScriptOptions{
+				TFArgs:       cmd.TFArgs,
+				Parallel:     cmd.Parallel,
+				LocalState:   cmd.LocalState,
+				ProviderFlag: cmd.ProviderFlag,
+			},
+			os.Stdout, ImportOptions{
+				OrderByDeps:       cmd.OrderByDeps,
+				Types:             cmd.Types,
+				Include:           cmd.Include,
+				Exclude:           cmd.Exclude,
+				Strict:            cmd.Strict,
+				AllowDuplicateIDs: cmd.AllowDupIDs,
+				Diagnostics:       diags,
+			})
+	case args.ImportDefs != nil && args.ImportDefs.Init != nil:
+		cmd := args.ImportDefs.Init
+		return doImportDefsInit(cmd.SrcPlanPath, cmd.TerraformBin.TerraformBin, os.Stdout)
+	case args.ImportDefs != nil && args.ImportDefs.Validate != nil:
+		return doImportDefsValidate(args.ImportDefs.Validate.ResourceDefs)
+	case args.ImportDefs != nil:
+		return parser.FailSubcommand("missing subcommand", "import-defs")
+	case args.MergeStates != nil:
+		return doMergeStates(args.MergeStates.State, args.MergeStates.Sources)
 	case args.Remove != nil:
 		cmd := args.Remove
-		return doRemove(cmd.Plan, cmd.Up)
+		return doRemove(cmd.Plan, cmd.Up, cmd.TerraformBin.TerraformBin)
 	case args.Version != nil:
 		fmt.Println("terravalet", fullVersion)
 		return nil
@@ -47,13 +47,43 @@ func TestParseSuccess(t *testing.T) {
 			wantCreate:  set.NewStringSet(),
 			wantDestroy: set.NewStringSet(),
 		},
+		{
+			name:        "ANSI colors are stripped",
+			line:        "  \x1b[1m  # aws_instance.bar\x1b[0m will be \x1b[1m\x1b[31mdestroyed\x1b[0m",
+			wantCreate:  set.NewStringSet(),
+			wantDestroy: set.NewStringSet("aws_instance.bar"),
+		},
+		{
+			name:        "Terraform Cloud timestamp prefix is tolerated",
+			line:        "2024-01-31T10:00:00.000Z   # aws_instance.bar will be created\r",
+			wantCreate:  set.NewStringSet("aws_instance.bar"),
+			wantDestroy: set.NewStringSet(),
+		},
+		{
+			name:        "Atlantis diff marker is tolerated",
+			line:        "+ # aws_instance.bar will be created",
+			wantCreate:  set.NewStringSet("aws_instance.bar"),
+			wantDestroy: set.NewStringSet(),
+		},
+		{
+			name:        "no changes is not an error",
+			line:        "No changes. Your infrastructure matches the configuration.",
+			wantCreate:  set.NewStringSet(),
+			wantDestroy: set.NewStringSet(),
+		},
+		{
+			name:        "empty plan is not an error",
+			line:        "\n\n",
+			wantCreate:  set.NewStringSet(),
+			wantDestroy: set.NewStringSet(),
+		},
 	}
 
 	for _, tc := range testCases {
 		t.Run(tc.name, func(t *testing.T) {
 			rd := strings.NewReader(tc.line)
 
-			haveCreate, haveDestroy, err := parse(rd)
+			haveCreate, haveDestroy, _, err := parse(rd)
 
 			if err != nil {
 				t.Fatalf("\nhave: %q\nwant: no error", err)
@@ -68,6 +98,82 @@ func TestParseSuccess(t *testing.T) {
 	}
 }
 
+func TestParseMoved(t *testing.T) {
+	testCases := []struct {
+		name        string
+		plan        string
+		wantCreate  *strset.Set
+		wantDestroy *strset.Set
+		wantMoved   map[string]string
+	}{
+		{
+			name:        "has moved to is recorded",
+			plan:        "  # aws_instance.foo has moved to aws_instance.bar",
+			wantCreate:  set.NewStringSet(),
+			wantDestroy: set.NewStringSet(),
+			wantMoved:   map[string]string{"aws_instance.foo": "aws_instance.bar"},
+		},
+		{
+			name: "moved from is recorded",
+			plan: `  # aws_instance.bar will be updated in-place
+  # (moved from aws_instance.foo)`,
+			wantCreate:  set.NewStringSet(),
+			wantDestroy: set.NewStringSet(),
+			wantMoved:   map[string]string{"aws_instance.foo": "aws_instance.bar"},
+		},
+		{
+			name: "moved elements do not take part in matching",
+			plan: `  # aws_instance.foo has moved to aws_instance.bar
+  # aws_instance.foo will be destroyed
+  # module.a.aws_instance.bar will be created
+  # aws_instance.bar will be destroyed`,
+			wantCreate:  set.NewStringSet("module.a.aws_instance.bar"),
+			wantDestroy: set.NewStringSet("aws_instance.bar"),
+			wantMoved:   map[string]string{"aws_instance.foo": "aws_instance.bar"},
+		},
+		{
+			name: "moved from after must be replaced",
+			plan: `  # aws_instance.foo will be destroyed
+  # module.a.aws_instance.foo will be created
+  # aws_instance.new must be replaced
+  # (moved from aws_instance.old)`,
+			wantCreate:  set.NewStringSet("module.a.aws_instance.foo"),
+			wantDestroy: set.NewStringSet("aws_instance.foo"),
+			wantMoved:   map[string]string{"aws_instance.old": "aws_instance.new"},
+		},
+		{
+			name: "moved from after is tainted",
+			plan: `  # module.a.aws_instance.foo will be created
+  # aws_instance.new is tainted, so must be replaced
+  # (moved from aws_instance.old)`,
+			wantCreate:  set.NewStringSet("module.a.aws_instance.foo"),
+			wantDestroy: set.NewStringSet(),
+			wantMoved:   map[string]string{"aws_instance.old": "aws_instance.new"},
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			rd := strings.NewReader(tc.plan)
+
+			haveCreate, haveDestroy, haveMoved, err := parse(rd)
+
+			if err != nil {
+				t.Fatalf("\nhave: %q\nwant: no error", err)
+			}
+			if diff := cmp.Diff(tc.wantCreate, haveCreate, setCmp); diff != "" {
+				t.Errorf("\ncreate: mismatch (-want +have):\n%s", diff)
+			}
+			if diff := cmp.Diff(tc.wantDestroy, haveDestroy, setCmp); diff != "" {
+				t.Errorf("\ndestroy: mismatch (-want +have):\n%s", diff)
+			}
+			if diff := cmp.Diff(tc.wantMoved, haveMoved); diff != "" {
+				t.Errorf("\nmoved: mismatch (-want +have):\n%s", diff)
+			}
+		})
+	}
+}
+
 func TestParseFailure(t *testing.T) {
 	testCases := []struct {
 		name    string
@@ -79,13 +185,29 @@ func TestParseFailure(t *testing.T) {
 			line:    "  # aws_instance.bar will be vaporized",
 			wantErr: `line "  # aws_instance.bar will be vaporized", unexpected action "vaporized"`,
 		},
+		{
+			name: "no recognizable resources",
+			line: "Terraform will perform the following actions:\n  + resource \"aws_instance\" \"bar\" {",
+			wantErr: "plan is not empty but contains no recognizable resources " +
+				"(is it the output of 'terraform plan -no-color'?)",
+		},
+		{
+			name:    "moved from after has moved to",
+			line:    "  # aws_instance.a has moved to aws_instance.b\n  # (moved from aws_instance.c)",
+			wantErr: `line "  # (moved from aws_instance.c)": moved from without a resource`,
+		},
+		{
+			name:    "JSON plan with a change without actions",
+			line:    `{"resource_changes": [{"address": "aws_instance.bar", "change": {"actions": []}}]}`,
+			wantErr: "resource aws_instance.bar: change has no actions",
+		},
 	}
 
 	for _, tc := range testCases {
 		t.Run(tc.name, func(t *testing.T) {
 			rd := strings.NewReader(tc.line)
 
-			_, _, err := parse(rd)
+			_, _, _, err := parse(rd)
 
 			if err == nil {
 				t.Fatalf("\nhave: no error\nwant: %q", tc.wantErr)
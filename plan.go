@@ -0,0 +1,289 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"strings"
+
+	"github.com/scylladb/go-set"
+	"github.com/scylladb/go-set/strset"
+)
+
+// The magic number of a binary plan, as saved by "terraform plan -out": it is a zip
+// archive.
+var binaryPlanMagic = []byte("PK\x03\x04")
+
+// The plan path that means standard input.
+const stdinPath = "-"
+
+// openPlan opens the plan file at path, or standard input if path is "-". If it is a
+// binary plan, it converts it to JSON by running "terraformBin show -json path" and
+// caches the result next to the plan, in path + ".json". The cache is used as long
+// as it is newer than the plan. Failing to write the cache is only a warning.
+//
+// The format of the plan (text, streaming JSON or JSON) is detected by the caller.
+func openPlan(path, terraformBin string) (io.ReadCloser, error) {
+	if path == stdinPath {
+		rd := bufio.NewReader(os.Stdin)
+		// Peek returns an error for an input shorter than the magic, which is fine.
+		if magic, _ := rd.Peek(len(binaryPlanMagic)); bytes.Equal(magic, binaryPlanMagic) {
+			return nil, fmt.Errorf("reading a binary plan from stdin is not supported; " +
+				"pipe the output of 'terraform show -json' instead")
+		}
+		return io.NopCloser(rd), nil
+	}
+
+	planFile, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	magic := make([]byte, len(binaryPlanMagic))
+	if _, err := io.ReadFull(planFile, magic); err != nil || !bytes.Equal(magic, binaryPlanMagic) {
+		// Not a binary plan (a file shorter than the magic is not a binary plan either).
+		if _, err := planFile.Seek(0, io.SeekStart); err != nil {
+			planFile.Close()
+			return nil, err
+		}
+		return planFile, nil
+	}
+	planInfo, err := planFile.Stat()
+	planFile.Close()
+	if err != nil {
+		return nil, err
+	}
+
+	cachePath := path + ".json"
+	if cacheInfo, err := os.Stat(cachePath); err == nil &&
+		!cacheInfo.ModTime().Before(planInfo.ModTime()) {
+		return os.Open(cachePath)
+	}
+
+	var stdout, stderr bytes.Buffer
+	cmd := exec.Command(terraformBin, "show", "-json", path)
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+	if err := cmd.Run(); err != nil {
+		return nil, fmt.Errorf("converting binary plan %s: %s show -json: %s: %s",
+			path, terraformBin, err, strings.TrimSpace(stderr.String()))
+	}
+	if err := os.WriteFile(cachePath, stdout.Bytes(), 0o644); err != nil {
+		// The cache only saves the next conversion.
+		fmt.Fprintf(os.Stderr, "warning: caching the converted binary plan: %s\n", err)
+	}
+
+	return io.NopCloser(bytes.NewReader(stdout.Bytes())), nil
+}
+
+// isJSONPlan reports whether data looks like the output of "terraform show -json",
+// that is a single JSON object. Use isStream first, since also a stream starts
+// with a JSON object.
+func isJSONPlan(data []byte) bool {
+	return bytes.HasPrefix(bytes.TrimSpace(data), []byte("{"))
+}
+
+// A message of the streaming output of "terraform plan -json" (the Terraform
+// "machine-readable UI"). Each line of the output is one message. We decode only
+// the fields we need.
+// See https://developer.hashicorp.com/terraform/internals/machine-readable-ui
+type streamMessage struct {
+	Level   string `json:"@level"`
+	Message string `json:"@message"`
+	Type    string `json:"type"`
+	// Present when Type is "planned_change" or "resource_drift".
+	Change struct {
+		Resource         streamResource  `json:"resource"`
+		PreviousResource *streamResource `json:"previous_resource"`
+		Action           string          `json:"action"`
+	} `json:"change"`
+	// Present when Type is "change_summary".
+	Changes *struct {
+		Add    int `json:"add"`
+		Change int `json:"change"`
+		Remove int `json:"remove"`
+	} `json:"changes"`
+	// Present when Type is "diagnostic".
+	Diagnostic struct {
+		Severity string `json:"severity"`
+		Summary  string `json:"summary"`
+	} `json:"diagnostic"`
+}
+
+type streamResource struct {
+	Addr            string `json:"addr"`
+	ResourceType    string `json:"resource_type"`
+	ImpliedProvider string `json:"implied_provider"`
+}
+
+// Map the action of a planned_change message to the list of actions of the
+// "terraform show -json" format, so that both formats can share ResourceChange.
+var streamActions = map[string][]string{
+	"noop":    {"no-op"},
+	"create":  {"create"},
+	"read":    {"read"},
+	"update":  {"update"},
+	"replace": {"delete", "create"},
+	"delete":  {"delete"},
+	"move":    {"no-op"},
+	"import":  {"no-op"},
+	"remove":  {"forget"},
+}
+
+// isStream reports whether data looks like the streaming output of
+// "terraform plan -json", that is one JSON object per line, each with a "type" field.
+// The output of "terraform show -json" is a single JSON object without a "type"
+// field, so it is not a stream.
+func isStream(data []byte) bool {
+	line, _, _ := bytes.Cut(bytes.TrimSpace(data), []byte("\n"))
+	var msg struct {
+		Type string `json:"type"`
+	}
+	if err := json.Unmarshal(line, &msg); err != nil {
+		return false
+	}
+	return msg.Type != ""
+}
+
+// readStream parses the streaming output of "terraform plan -json" and returns the
+// planned changes. Resource drift and all other messages are ignored.
+//
+// If the stream contains a change_summary message, the number of planned changes is
+// validated against it, to detect a truncated or otherwise corrupted stream.
+func readStream(rd io.Reader) ([]ResourceChange, error) {
+	var changes []ResourceChange
+	var add, change, remove int
+	summaryFound := false
+
+	scanner := bufio.NewScanner(rd)
+	// A message can be longer than the default maximum token size of the Scanner.
+	scanner.Buffer(make([]byte, 0, 64*1024), 16*1024*1024)
+	for lineNum := 1; scanner.Scan(); lineNum++ {
+		line := bytes.TrimSpace(scanner.Bytes())
+		if len(line) == 0 {
+			continue
+		}
+		var msg streamMessage
+		if err := json.Unmarshal(line, &msg); err != nil {
+			return nil, fmt.Errorf("streaming plan: line %d: %s", lineNum, err)
+		}
+		switch msg.Type {
+		case "planned_change":
+			actions, ok := streamActions[msg.Change.Action]
+			if !ok {
+				return nil, fmt.Errorf("streaming plan: line %d: unexpected action %q",
+					lineNum, msg.Change.Action)
+			}
+			res := ResourceChange{
+				Address:      msg.Change.Resource.Addr,
+				Type:         msg.Change.Resource.ResourceType,
+				ProviderName: msg.Change.Resource.ImpliedProvider,
+			}
+			res.Change.Actions = actions
+			if prev := msg.Change.PreviousResource; prev != nil {
+				res.PreviousAddress = prev.Addr
+			}
+			changes = append(changes, res)
+
+			switch msg.Change.Action {
+			case "create":
+				add++
+			case "update":
+				change++
+			case "delete":
+				remove++
+			case "replace":
+				add++
+				remove++
+			}
+		case "change_summary":
+			if msg.Changes == nil {
+				return nil, fmt.Errorf("streaming plan: line %d: change_summary without changes",
+					lineNum)
+			}
+			summaryFound = true
+			if msg.Changes.Add != add || msg.Changes.Change != change ||
+				msg.Changes.Remove != remove {
+				return nil, fmt.Errorf("streaming plan: change_summary mismatch: "+
+					"have: %d to add, %d to change, %d to destroy; "+
+					"want: %d to add, %d to change, %d to destroy",
+					add, change, remove,
+					msg.Changes.Add, msg.Changes.Change, msg.Changes.Remove)
+			}
+		case "diagnostic":
+			if msg.Diagnostic.Severity == "error" {
+				return nil, fmt.Errorf("streaming plan: line %d: plan failed: %s",
+					lineNum, msg.Diagnostic.Summary)
+			}
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("streaming plan: %s", err)
+	}
+	if !summaryFound && len(changes) > 0 {
+		// Terraform always emits the summary at the end of a successful plan.
+		return nil, fmt.Errorf("streaming plan: missing change_summary (truncated plan?)")
+	}
+
+	return changes, nil
+}
+
+// The kind of a planned change, as far as terravalet is concerned.
+type changeKind int
+
+const (
+	// No-op, read, update, forget.
+	changeOther changeKind = iota
+	changeCreate
+	changeDelete
+	// Delete then create or, with create_before_destroy, create then delete. The
+	// resource already exists, so it is neither to create nor to destroy.
+	changeReplace
+)
+
+// kind classifies the actions of the planned change res.
+func (res ResourceChange) kind() (changeKind, error) {
+	switch strings.Join(res.Change.Actions, ",") {
+	case "":
+		return changeOther, fmt.Errorf("resource %s: change has no actions", res.Address)
+	case "create":
+		return changeCreate, nil
+	case "delete":
+		return changeDelete, nil
+	case "delete,create", "create,delete":
+		return changeReplace, nil
+	default:
+		return changeOther, nil
+	}
+}
+
+// changeSets returns the same sets as parse: the addresses to create, the addresses
+// to destroy and the map old -> new of the addresses moved by a "moved" block.
+func changeSets(changes []ResourceChange,
+) (*strset.Set, *strset.Set, map[string]string, error) {
+	create := set.NewStringSet()
+	destroy := set.NewStringSet()
+	moved := map[string]string{}
+
+	for _, res := range changes {
+		if res.PreviousAddress != "" && res.PreviousAddress != res.Address {
+			moved[res.PreviousAddress] = res.Address
+			continue
+		}
+		kind, err := res.kind()
+		if err != nil {
+			return set.NewStringSet(), set.NewStringSet(), map[string]string{}, err
+		}
+		switch kind {
+		case changeCreate:
+			create.Add(res.Address)
+		case changeDelete:
+			destroy.Add(res.Address)
+		}
+	}
+
+	return create, destroy, moved, nil
+}
@@ -0,0 +1,183 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/go-quicktest/qt"
+	"github.com/google/go-cmp/cmp"
+	"github.com/scylladb/go-set"
+)
+
+const streamPlan = `{"@level":"info","@message":"Terraform 1.9.5","@module":"terraform.ui","type":"version","terraform":"1.9.5","ui":"1.2"}
+{"@level":"info","@message":"aws_instance.foo: Drift detected (update)","type":"resource_drift","change":{"resource":{"addr":"aws_instance.foo","resource_type":"aws_instance"},"action":"update"}}
+{"@level":"info","@message":"aws_instance.bar: Plan to create","type":"planned_change","change":{"resource":{"addr":"aws_instance.bar","resource_type":"aws_instance","implied_provider":"aws"},"action":"create"}}
+{"@level":"info","@message":"module.ci.aws_instance.bar: Plan to delete","type":"planned_change","change":{"resource":{"addr":"module.ci.aws_instance.bar","resource_type":"aws_instance","implied_provider":"aws"},"action":"delete"}}
+{"@level":"info","@message":"aws_instance.baz: Plan to update","type":"planned_change","change":{"resource":{"addr":"aws_instance.baz","resource_type":"aws_instance","implied_provider":"aws"},"previous_resource":{"addr":"module.ci.aws_instance.baz","resource_type":"aws_instance","implied_provider":"aws"},"action":"update"}}
+{"@level":"info","@message":"aws_instance.qux: Plan to replace","type":"planned_change","change":{"resource":{"addr":"aws_instance.qux","resource_type":"aws_instance","implied_provider":"aws"},"action":"replace"}}
+{"@level":"info","@message":"Plan: 2 to add, 1 to change, 2 to destroy.","type":"change_summary","changes":{"add":2,"change":1,"import":0,"remove":2,"operation":"plan"}}
+`
+
+func TestIsStream(t *testing.T) {
+	qt.Assert(t, qt.IsTrue(isStream([]byte(streamPlan))))
+	qt.Assert(t, qt.IsFalse(isStream([]byte(`{"format_version":"1.2","resource_changes":[]}`))))
+	qt.Assert(t, qt.IsFalse(isStream([]byte("  # aws_instance.bar will be created"))))
+}
+
+func TestParseStreamSuccess(t *testing.T) {
+	create, destroy, moved, err := parse(strings.NewReader(streamPlan))
+
+	qt.Assert(t, qt.IsNil(err))
+	if diff := cmp.Diff(set.NewStringSet("aws_instance.bar"), create, setCmp); diff != "" {
+		t.Errorf("\ncreate: mismatch (-want +have):\n%s", diff)
+	}
+	if diff := cmp.Diff(set.NewStringSet("module.ci.aws_instance.bar"), destroy, setCmp); diff != "" {
+		t.Errorf("\ndestroy: mismatch (-want +have):\n%s", diff)
+	}
+	qt.Assert(t, qt.DeepEquals(moved,
+		map[string]string{"module.ci.aws_instance.baz": "aws_instance.baz"}))
+}
+
+func TestReadStreamFailure(t *testing.T) {
+	testCases := []struct {
+		name    string
+		stream  string
+		wantErr string
+	}{
+		{
+			name: "change_summary mismatch",
+			stream: `{"type":"planned_change","change":{"resource":{"addr":"a.b"},"action":"create"}}
+{"type":"change_summary","changes":{"add":2,"change":0,"remove":0}}`,
+			wantErr: "streaming plan: change_summary mismatch: " +
+				"have: 1 to add, 0 to change, 0 to destroy; " +
+				"want: 2 to add, 0 to change, 0 to destroy",
+		},
+		{
+			name:    "missing change_summary",
+			stream:  `{"type":"planned_change","change":{"resource":{"addr":"a.b"},"action":"create"}}`,
+			wantErr: "streaming plan: missing change_summary (truncated plan?)",
+		},
+		{
+			name: "unexpected action",
+			stream: `{"type":"version"}
+{"type":"planned_change","change":{"resource":{"addr":"a.b"},"action":"vaporize"}}`,
+			wantErr: `streaming plan: line 2: unexpected action "vaporize"`,
+		},
+		{
+			name: "error diagnostic",
+			stream: `{"type":"version"}
+{"@level":"error","type":"diagnostic","diagnostic":{"severity":"error","summary":"Unsupported attribute"}}`,
+			wantErr: "streaming plan: line 2: plan failed: Unsupported attribute",
+		},
+		{
+			name: "invalid JSON",
+			stream: `{"type":"version"}
+{"type":`,
+			wantErr: "streaming plan: line 2: unexpected end of JSON input",
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			_, err := readStream(strings.NewReader(tc.stream))
+
+			qt.Assert(t, qt.IsNotNil(err))
+			qt.Assert(t, qt.Equals(err.Error(), tc.wantErr))
+		})
+	}
+}
+
+func TestRunRemoveBinaryPlan(t *testing.T) {
+	tmpDir := t.TempDir()
+	planPath := filepath.Join(tmpDir, "plan.bin")
+	upPath := filepath.Join(tmpDir, "up.sh")
+	// Only the magic number matters.
+	qt.Assert(t, qt.IsNil(os.WriteFile(planPath, []byte("PK\x03\x04binary"), 0o644)))
+
+	// Stub terraform executable, found via PATH.
+	binDir := filepath.Join(tmpDir, "bin")
+	qt.Assert(t, qt.IsNil(os.Mkdir(binDir, 0o755)))
+	stub := `#! /bin/sh
+test "$1 $2" = "show -json" || exit 1
+echo '{"resource_changes":[{"address":"aws_instance.foo","change":{"actions":["delete"]}}]}'
+`
+	qt.Assert(t, qt.IsNil(os.WriteFile(filepath.Join(binDir, "terraform"), []byte(stub), 0o755)))
+	t.Setenv("PATH", binDir+string(os.PathListSeparator)+os.Getenv("PATH"))
+
+	want := `#! /bin/sh
+# DO NOT EDIT. Generated by https://github.com/pix4D/terravalet
+# This script will remove 1 items.
+
+set -e
+
+terraform state rm 'aws_instance.foo'
+
+`
+	setArgs(t, "terravalet", "remove", "--plan", planPath, "--up", upPath)
+	qt.Assert(t, qt.IsNil(run()))
+	have, err := os.ReadFile(upPath)
+	qt.Assert(t, qt.IsNil(err))
+	qt.Assert(t, qt.Equals(string(have), want))
+	qt.Assert(t, qt.IsTrue(fileExists(planPath+".json")))
+
+	// The second time, the cached conversion is used, without running terraform.
+	qt.Assert(t, qt.IsNil(os.Remove(filepath.Join(binDir, "terraform"))))
+	qt.Assert(t, qt.IsNil(run()))
+	have, err = os.ReadFile(upPath)
+	qt.Assert(t, qt.IsNil(err))
+	qt.Assert(t, qt.Equals(string(have), want))
+}
+
+func TestRunRemoveBinaryPlanFailure(t *testing.T) {
+	tmpDir := t.TempDir()
+	planPath := filepath.Join(tmpDir, "plan.bin")
+	qt.Assert(t, qt.IsNil(os.WriteFile(planPath, []byte("PK\x03\x04binary"), 0o644)))
+
+	setArgs(t, "terravalet", "remove", "--plan", planPath,
+		"--up", filepath.Join(tmpDir, "up.sh"), "--terraform-bin", "false")
+	err := run()
+
+	qt.Assert(t, qt.ErrorMatches(err,
+		`remove: opening the plan file: converting binary plan .*/plan.bin: false show -json: exit status 1: `))
+}
+
+func TestRunRemoveBinaryPlanCacheFailure(t *testing.T) {
+	tmpDir := t.TempDir()
+	planPath := filepath.Join(tmpDir, "plan.bin")
+	upPath := filepath.Join(tmpDir, "up.sh")
+	qt.Assert(t, qt.IsNil(os.WriteFile(planPath, []byte("PK\x03\x04binary"), 0o644)))
+	// The cache cannot be written over a directory, older than the plan so that it
+	// is not used as the cache.
+	qt.Assert(t, qt.IsNil(os.Mkdir(planPath+".json", 0o755)))
+	old := time.Now().Add(-time.Hour)
+	qt.Assert(t, qt.IsNil(os.Chtimes(planPath+".json", old, old)))
+
+	binDir := filepath.Join(tmpDir, "bin")
+	qt.Assert(t, qt.IsNil(os.Mkdir(binDir, 0o755)))
+	stub := `#! /bin/sh
+echo '{"resource_changes":[{"address":"aws_instance.foo","change":{"actions":["delete"]}}]}'
+`
+	qt.Assert(t, qt.IsNil(os.WriteFile(filepath.Join(binDir, "terraform"), []byte(stub), 0o755)))
+	t.Setenv("PATH", binDir+string(os.PathListSeparator)+os.Getenv("PATH"))
+
+	setArgs(t, "terravalet", "remove", "--plan", planPath, "--up", upPath)
+	qt.Assert(t, qt.IsNil(run()))
+	have, err := os.ReadFile(upPath)
+	qt.Assert(t, qt.IsNil(err))
+	qt.Assert(t, qt.StringContains(string(have), "terraform state rm 'aws_instance.foo'"))
+}
+
+// setArgs sets os.Args for run, restoring it at the end of the test.
+func setArgs(t *testing.T, args ...string) {
+	saved := os.Args
+	t.Cleanup(func() { os.Args = saved })
+	os.Args = args
+}
+
+func fileExists(path string) bool {
+	_, err := os.Stat(path)
+	return err == nil
+}
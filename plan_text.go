@@ -0,0 +1,353 @@
+package main
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"io"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+var (
+	// The header of a resource in the text plan. The address is a sequence of
+	// characters other than spaces and of quoted index keys, so that lines like
+	// "# Warning: this attribute value will be marked as sensitive" do not match.
+	reTextChange  = regexp.MustCompile(`(?:^|[\s+~-])# ((?:[^\s"]|"(?:[^"\\]|\\.)*")+) will be (.+?)\s*$`)
+	reTextReplace = regexp.MustCompile(`(?:^|[\s+~-])# ((?:[^\s"]|"(?:[^"\\]|\\.)*")+) (?:is tainted, so )?must be replaced\s*$`)
+	reTextMoved   = regexp.MustCompile(`(?:^|[\s+~-])# (?:[^\s"]|"(?:[^"\\]|\\.)*")+ has moved to `)
+	// The first line of the rendering of a resource, after its header.
+	reTextResource = regexp.MustCompile(`^(?:[-+~/<=]+\s+)?(?:resource|data) "([^"]+)" "[^"]+" \{$`)
+	// The change marker at the beginning of a line of the rendering of a resource.
+	reTextMarker = regexp.MustCompile(`^(?:[-+~]|-/\+|\+/-|<=)\s+`)
+	// An attribute, `name = VALUE` or `"key" = VALUE`, and a nested block, `name {`.
+	reTextAttr   = regexp.MustCompile(`^("(?:[^"\\]|\\.)*"|[^\s="]+)\s+=\s+(.*)$`)
+	reTextBlock  = regexp.MustCompile(`^([^\s="]+) \{$`)
+	reTextNumber = regexp.MustCompile(`^-?[0-9]+(?:\.[0-9]+)?(?:[eE][-+]?[0-9]+)?$`)
+)
+
+// Map the action of the header of a resource in the text plan ("# ADDR will be
+// ACTION") to the list of actions of the "terraform show -json" format.
+var textActions = map[string][]string{
+	"created":           {"create"},
+	"destroyed":         {"delete"},
+	"updated in-place":  {"update"},
+	"read during apply": {"read"},
+}
+
+// readTextPlan parses the output of "terraform plan -no-color" and returns the
+// planned changes, like readStream. For the resources to create, it reads also the
+// attribute values from their rendering (the `+ name = "foo"` lines), so that the
+// import IDs can be derived from them as with a JSON plan:
+//
+//   - `(known after apply)` marks the attribute as unknown until apply;
+//   - `(sensitive value)` marks the attribute as sensitive;
+//   - a nested block is a list of objects, as in the JSON plan; a block whose
+//     contents are not displayed because sensitive is marked as sensitive;
+//   - values rendered as an expression, like `jsonencode(...)`, are not available.
+//
+// The provider of a resource is not in the text plan: it is implied from the type,
+// as Terraform does (github for github_repository).
+func readTextPlan(rd io.Reader) ([]ResourceChange, error) {
+	var lines []string
+	scanner := bufio.NewScanner(rd)
+	scanner.Buffer(make([]byte, 0, 64*1024), 16*1024*1024)
+	for scanner.Scan() {
+		lines = append(lines, reANSI.ReplaceAllString(scanner.Text(), ""))
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("text plan: %s", err)
+	}
+
+	var changes []ResourceChange
+	// Used to detect a plan in an unexpected format, as parseText does.
+	nonBlank := 0
+	recognized := 0
+	noChanges := false
+
+	p := &textParser{lines: lines}
+	for p.pos < len(p.lines) {
+		line := p.lines[p.pos]
+		p.pos++
+		if strings.TrimSpace(line) == "" {
+			continue
+		}
+		nonBlank++
+		if strings.Contains(line, "No changes.") {
+			noChanges = true
+			continue
+		}
+
+		res := ResourceChange{}
+		if reTextMoved.MatchString(line) {
+			// Not a change. The change of the resource, if any, has its own header.
+			recognized++
+			continue
+		} else if m := reTextReplace.FindStringSubmatch(line); m != nil {
+			res.Address = m[1]
+			res.Change.Actions = []string{"delete", "create"}
+		} else if m := reTextChange.FindStringSubmatch(line); m != nil {
+			res.Address = m[1]
+			actions, ok := textActions[m[2]]
+			switch {
+			case ok:
+				res.Change.Actions = actions
+			case strings.HasPrefix(m[2], "replaced"):
+				// "will be replaced, as requested" (terraform plan -replace).
+				res.Change.Actions = []string{"delete", "create"}
+			default:
+				// Not a change of the resources, like "will be imported" or "will be
+				// forgotten" (Terraform >= 1.5). Its rendering, if any, has no header
+				// and is skipped like any other line.
+				recognized++
+				continue
+			}
+		} else {
+			continue
+		}
+		recognized++
+
+		// The rendering of the resource follows the header, possibly after some
+		// comments like "# (because ...)".
+		resLine, err := p.next()
+		if err != nil {
+			return nil, fmt.Errorf("text plan: resource %s: %s", res.Address, err)
+		}
+		m := reTextResource.FindStringSubmatch(resLine)
+		if m == nil {
+			return nil, fmt.Errorf(`text plan: line %d: resource %s: have %q; `+
+				`want: resource "TYPE" "NAME" {`, p.pos, res.Address, resLine)
+		}
+		res.Type = m[1]
+		res.ProviderName, _, _ = strings.Cut(res.Type, "_")
+
+		if res.Change.Actions[0] == "create" && len(res.Change.Actions) == 1 {
+			after, err := p.object()
+			if err != nil {
+				return nil, fmt.Errorf("text plan: resource %s: %s", res.Address, err)
+			}
+			res.Change.After = after.val
+			res.Change.AfterUnknown = after.unknown
+			res.Change.AfterSensitive = after.sensitive
+		}
+		changes = append(changes, res)
+	}
+
+	if nonBlank > 0 && recognized == 0 && !noChanges {
+		return nil, fmt.Errorf("plan is not empty but contains no recognizable resources " +
+			"(is it the output of 'terraform plan -no-color'?)")
+	}
+	return changes, nil
+}
+
+// The comment that replaces the contents of a nested block with sensitive attributes.
+const textSensitiveBlock = "# At least one attribute in this block is (or was) sensitive"
+
+// A parser of the rendering of the resources in the text plan.
+type textParser struct {
+	lines []string
+	pos   int // The index of the next line.
+}
+
+// A value of the text plan, with its masks in the format of "after_unknown" and
+// "after_sensitive" (see masked).
+type textValue struct {
+	val       interface{}
+	unknown   interface{}
+	sensitive interface{}
+	// The value is not in the text plan: unknown until apply, sensitive or rendered
+	// as an expression.
+	absent bool
+}
+
+// next returns the next line that is neither blank nor a comment, without the
+// surrounding spaces and the change marker.
+func (p *textParser) next() (string, error) {
+	for p.pos < len(p.lines) {
+		line := strings.TrimSpace(p.lines[p.pos])
+		p.pos++
+		line = reTextMarker.ReplaceAllString(line, "")
+		if line != "" && !strings.HasPrefix(line, "#") {
+			return line, nil
+		}
+	}
+	return "", fmt.Errorf("unexpected end of plan")
+}
+
+// object parses the attributes and the nested blocks of an object, up to its
+// closing brace.
+func (p *textParser) object() (textValue, error) {
+	obj := map[string]interface{}{}
+	unknown := map[string]interface{}{}
+	sensitive := map[string]interface{}{}
+	for {
+		line, err := p.next()
+		if err != nil {
+			return textValue{}, err
+		}
+		if line == "}" || line == "}," {
+			return textValue{val: obj, unknown: unknown, sensitive: sensitive}, nil
+		}
+
+		if m := reTextBlock.FindStringSubmatch(line); m != nil {
+			hidden := p.pos < len(p.lines) &&
+				strings.HasPrefix(strings.TrimSpace(p.lines[p.pos]), textSensitiveBlock)
+			block, err := p.object()
+			if err != nil {
+				return textValue{}, err
+			}
+			if hidden {
+				block.sensitive = true
+			}
+			list, _ := obj[m[1]].([]interface{})
+			obj[m[1]] = append(list, block.val)
+			unknown[m[1]] = appendMask(unknown[m[1]], len(list), block.unknown)
+			sensitive[m[1]] = appendMask(sensitive[m[1]], len(list), block.sensitive)
+			continue
+		}
+
+		m := reTextAttr.FindStringSubmatch(line)
+		if m == nil {
+			return textValue{}, fmt.Errorf("line %d: unexpected %q", p.pos, line)
+		}
+		key := m[1]
+		if strings.HasPrefix(key, `"`) {
+			if key, err = strconv.Unquote(key); err != nil {
+				return textValue{}, fmt.Errorf("line %d: key %s: %s", p.pos, m[1], err)
+			}
+		}
+		val, err := p.value(m[2])
+		if err != nil {
+			return textValue{}, err
+		}
+		if !val.absent {
+			obj[key] = val.val
+		}
+		if val.unknown != nil {
+			unknown[key] = val.unknown
+		}
+		if val.sensitive != nil {
+			sensitive[key] = val.sensitive
+		}
+	}
+}
+
+// list parses the elements of a list, up to its closing bracket.
+func (p *textParser) list() (textValue, error) {
+	list := []interface{}{}
+	unknown := []interface{}{}
+	sensitive := []interface{}{}
+	for {
+		line, err := p.next()
+		if err != nil {
+			return textValue{}, err
+		}
+		if line == "]" || line == "]," {
+			return textValue{val: list, unknown: unknown, sensitive: sensitive}, nil
+		}
+		// Each element is followed by a comma, except the objects and the lists,
+		// whose closing brace or bracket is.
+		elem, err := p.value(strings.TrimSuffix(line, ","))
+		if err != nil {
+			return textValue{}, err
+		}
+		list = append(list, elem.val)
+		unknown = appendMask(unknown, len(list)-1, elem.unknown)
+		sensitive = appendMask(sensitive, len(list)-1, elem.sensitive)
+	}
+}
+
+// value parses the value s, the rest of the line after "name = " or a list element,
+// and the following lines for the objects, lists and heredoc strings.
+func (p *textParser) value(s string) (textValue, error) {
+	switch {
+	case s == "(known after apply)":
+		return textValue{unknown: true, absent: true}, nil
+	case s == "(sensitive value)" || s == "(sensitive)":
+		return textValue{sensitive: true, absent: true}, nil
+	case s == "null":
+		return textValue{val: nil}, nil
+	case s == "true" || s == "false":
+		return textValue{val: s == "true"}, nil
+	case reTextNumber.MatchString(s):
+		return textValue{val: json.Number(s)}, nil
+	case strings.HasPrefix(s, `"`):
+		str, err := strconv.Unquote(s)
+		if err != nil {
+			return textValue{}, fmt.Errorf("line %d: string %s: %s", p.pos, s, err)
+		}
+		// Terraform escapes the template sequences.
+		str = strings.NewReplacer("$${", "${", "%%{", "%{").Replace(str)
+		return textValue{val: str}, nil
+	case s == "[]":
+		return textValue{val: []interface{}{}}, nil
+	case s == "[":
+		return p.list()
+	case s == "{}":
+		return textValue{val: map[string]interface{}{}}, nil
+	case s == "{":
+		return p.object()
+	case strings.HasPrefix(s, "<<"):
+		return p.heredoc(strings.TrimPrefix(strings.TrimPrefix(s, "<<"), "-"))
+	case strings.HasSuffix(s, "("):
+		// An expression like jsonencode(, up to the line with the closing parenthesis.
+		for p.pos < len(p.lines) {
+			line := strings.TrimSpace(p.lines[p.pos])
+			p.pos++
+			if line == ")" || line == ")," {
+				return textValue{absent: true}, nil
+			}
+		}
+		return textValue{}, fmt.Errorf("unexpected end of plan")
+	default:
+		return textValue{}, fmt.Errorf("line %d: unexpected value %q", p.pos, s)
+	}
+}
+
+// heredoc parses the lines of a heredoc string, up to the line with marker. As with
+// "<<-", the indentation common to all the lines is removed.
+func (p *textParser) heredoc(marker string) (textValue, error) {
+	var lines []string
+	for p.pos < len(p.lines) {
+		line := p.lines[p.pos]
+		p.pos++
+		if strings.TrimSpace(line) == marker {
+			indent := -1
+			for _, l := range lines {
+				if strings.TrimSpace(l) == "" {
+					continue
+				}
+				n := len(l) - len(strings.TrimLeft(l, " "))
+				if indent < 0 || n < indent {
+					indent = n
+				}
+			}
+			var bld strings.Builder
+			for _, l := range lines {
+				if strings.TrimSpace(l) == "" {
+					l = ""
+				} else if indent > 0 {
+					l = l[indent:]
+				}
+				bld.WriteString(l + "\n")
+			}
+			return textValue{val: bld.String()}, nil
+		}
+		lines = append(lines, line)
+	}
+	return textValue{}, fmt.Errorf("heredoc: missing %s", marker)
+}
+
+// appendMask sets the mask of the element at index i of a list to elem, or false
+// if nil, padding the list of masks with false.
+func appendMask(masks interface{}, i int, elem interface{}) []interface{} {
+	list, _ := masks.([]interface{})
+	for len(list) < i {
+		list = append(list, false)
+	}
+	if elem == nil {
+		elem = false
+	}
+	return append(list[:i], elem)
+}
@@ -0,0 +1,155 @@
+package main
+
+import (
+	"encoding/json"
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/go-quicktest/qt"
+)
+
+func TestReadTextPlanSuccess(t *testing.T) {
+	plan, err := os.Open("testdata/import/35_import_src-plan.txt")
+	qt.Assert(t, qt.IsNil(err))
+	defer plan.Close()
+
+	changes, err := readTextPlan(plan)
+
+	qt.Assert(t, qt.IsNil(err))
+	var have []string
+	for _, res := range changes {
+		have = append(have, strings.Join([]string{res.Address, res.Type, res.ProviderName,
+			strings.Join(res.Change.Actions, ",")}, " "))
+	}
+	qt.Assert(t, qt.DeepEquals(have, []string{
+		"data.github_user.me github_user github read",
+		"aws_instance.web aws_instance aws create",
+		"aws_instance.old aws_instance aws delete,create",
+		"github_repository.foo github_repository github update",
+		`github_team_repository.all["foo.developers"] github_team_repository github create`,
+		`module.repo["foo"].github_repository_environment.envs["prod"] ` +
+			"github_repository_environment github create",
+	}))
+
+	web := changes[1].Change
+	qt.Assert(t, qt.DeepEquals(web.After, interface{}(map[string]interface{}{
+		"ami":           "ami-0123456789",
+		"instance_type": "t3.micro",
+		"tags":          map[string]interface{}{"Name": "web"},
+		"user_data":     "#!/bin/sh\necho \"${HOSTNAME}\"\n",
+		"network_interface": []interface{}{map[string]interface{}{
+			"delete_on_termination": false,
+			"device_index":          json.Number("0"),
+		}},
+	})))
+	qt.Assert(t, qt.IsTrue(masked(web.AfterUnknown, []pathStep{{key: "arn"}})))
+	qt.Assert(t, qt.IsTrue(masked(web.AfterUnknown, []pathStep{
+		{key: "network_interface"}, {index: 0, isIndex: true}, {key: "network_interface_id"},
+	})))
+	qt.Assert(t, qt.IsFalse(masked(web.AfterUnknown, []pathStep{{key: "ami"}})))
+
+	env := changes[5].Change
+	qt.Assert(t, qt.DeepEquals(env.After, interface{}(map[string]interface{}{
+		"environment": "prod",
+		"repository":  "foo",
+		"reviewers": []interface{}{map[string]interface{}{
+			"teams": []interface{}{json.Number("2817139")},
+			"users": []interface{}{},
+		}},
+	})))
+}
+
+func TestReadTextPlanSensitive(t *testing.T) {
+	const plan = `
+  # aws_db_instance.db will be created
+  + resource "aws_db_instance" "db" {
+      + identifier = "db"
+      + password   = (sensitive value)
+      + tags       = {
+          + "Owner" = (sensitive value)
+        }
+      + settings {
+          # At least one attribute in this block is (or was) sensitive,
+          # so its contents will not be displayed.
+        }
+    }
+`
+	changes, err := readTextPlan(strings.NewReader(plan))
+
+	qt.Assert(t, qt.IsNil(err))
+	db := changes[0].Change
+	qt.Assert(t, qt.DeepEquals(db.After, interface{}(map[string]interface{}{
+		"identifier": "db",
+		"tags":       map[string]interface{}{},
+		"settings":   []interface{}{map[string]interface{}{}},
+	})))
+	qt.Assert(t, qt.IsTrue(masked(db.AfterSensitive, []pathStep{{key: "password"}})))
+	qt.Assert(t, qt.IsTrue(masked(db.AfterSensitive, []pathStep{{key: "tags"}, {key: "Owner"}})))
+	qt.Assert(t, qt.IsFalse(masked(db.AfterSensitive, []pathStep{{key: "identifier"}})))
+	qt.Assert(t, qt.IsTrue(masked(db.AfterSensitive, []pathStep{
+		{key: "settings"}, {index: 0, isIndex: true}, {key: "name"},
+	})))
+}
+
+func TestReadTextPlanOtherActions(t *testing.T) {
+	const plan = `
+  # aws_instance.old will be forgotten
+  . resource "aws_instance" "old" {
+        id = "i-0123"
+    }
+
+  # aws_instance.web will be imported
+    resource "aws_instance" "web" {
+        ami = "ami-0123456789"
+        id  = "i-4567"
+    }
+
+  # aws_instance.new will be created
+  + resource "aws_instance" "new" {
+      + ami = "ami-0123456789"
+    }
+`
+	changes, err := readTextPlan(strings.NewReader(plan))
+
+	qt.Assert(t, qt.IsNil(err))
+	qt.Assert(t, qt.HasLen(changes, 1))
+	qt.Assert(t, qt.Equals(changes[0].Address, "aws_instance.new"))
+}
+
+func TestReadTextPlanFailure(t *testing.T) {
+	testCases := []struct {
+		name    string
+		plan    string
+		wantErr string
+	}{
+		{
+			name:    "not a plan",
+			plan:    "hello\nworld\n",
+			wantErr: "plan is not empty but contains no recognizable resources .*",
+		},
+		{
+			name:    "missing resource line",
+			plan:    "  # a.b will be created\n  + name = \"x\"\n",
+			wantErr: `text plan: line 2: resource a.b: have "name = \\"x\\""; want: resource "TYPE" "NAME" {`,
+		},
+		{
+			name:    "truncated resource",
+			plan:    "  # a.b will be created\n  + resource \"a\" \"b\" {\n      + name = \"x\"\n",
+			wantErr: "text plan: resource a.b: unexpected end of plan",
+		},
+		{
+			name:    "unexpected value",
+			plan:    "  # a.b will be created\n  + resource \"a\" \"b\" {\n      + name = x\n    }\n",
+			wantErr: `text plan: resource a.b: line 3: unexpected value "x"`,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			_, err := readTextPlan(strings.NewReader(tc.plan))
+
+			qt.Assert(t, qt.ErrorMatches(err, tc.wantErr))
+		})
+	}
+}
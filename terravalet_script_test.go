@@ -6,6 +6,7 @@
 package main
 
 import (
+	"os"
 	"testing"
 
 	"github.com/rogpeppe/go-internal/testscript"
@@ -13,7 +14,7 @@ import (
 
 func TestMain(m *testing.M) {
 	testscript.Main(m, map[string]func(){
-		"terravalet": func() { Main() },
+		"terravalet": func() { os.Exit(Main()) },
 	})
 }
 
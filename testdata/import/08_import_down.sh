@@ -42,11 +42,11 @@ terraform state rm \
     "module.github.github_branch_default.default[\"test-import-gh\"]"
 
 terraform state rm \
-    "module.github.github_repository.repos[\"test-import-gh\"]"
+    "module.github.github_repository.repos[\"test-import-bar\"]"
 
 terraform state rm \
     "module.github.github_repository.repos[\"test-import-foo\"]"
 
 terraform state rm \
-    "module.github.github_repository.repos[\"test-import-bar\"]"
+    "module.github.github_repository.repos[\"test-import-gh\"]"
 
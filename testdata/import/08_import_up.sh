@@ -9,13 +9,13 @@
 set -x
 
 terraform import \
-    "module.github.github_repository.repos[\"test-import-bar\"]" "test-import-bar"
+    "module.github.github_repository.repos[\"test-import-gh\"]" "test-import-gh"
 
 terraform import \
     "module.github.github_repository.repos[\"test-import-foo\"]" "test-import-foo"
 
 terraform import \
-    "module.github.github_repository.repos[\"test-import-gh\"]" "test-import-gh"
+    "module.github.github_repository.repos[\"test-import-bar\"]" "test-import-bar"
 
 terraform import \
     "module.github.github_branch_default.default[\"test-import-gh\"]" "test-import-gh"
@@ -0,0 +1,19 @@
+# Same as 18_import_priority_definitions.json.
+
+# Repositories first, then branches, then branch protections.
+definition "github_repository" {
+  priority  = 2
+  variables = ["name"]
+}
+
+definition "github_branch" {
+  priority  = 1
+  separator = ":"
+  variables = ["repository", "branch"]
+}
+
+// Comments in C++ style are also accepted.
+definition "github_branch_protection" {
+  separator = ":"
+  variables = ["repository_id", "pattern"]
+}